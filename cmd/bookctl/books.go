@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// csvHeader is the header of exported CSV files. Imports need the title, author and quantity columns.
+var csvHeader = []string{"id", "title", "author", "quantity", "available"}
+
+// exportBooks prints the catalog, or writes it to a JSON or CSV file
+func exportBooks(app *app, args []string) error {
+	flags := flag.NewFlagSet("books export", flag.ExitOnError)
+	path := flags.String("file", "", "file to write, CSV if it ends in .csv and JSON otherwise")
+	flags.Parse(args)
+
+	books, err := app.books.GetAllBooks()
+	if err != nil {
+		return err
+	}
+
+	if *path == "" {
+		rows := make([][]string, 0, len(books))
+		for _, book := range books {
+			rows = append(rows, bookRow(&book))
+		}
+		return app.out.print(books, []string{"ID", "TITLE", "AUTHOR", "QUANTITY", "AVAILABLE"}, rows)
+	}
+
+	file, err := os.Create(*path)
+	if err != nil {
+		return err
+	}
+	if isCSV(*path) {
+		err = writeBooksCSV(file, books)
+	} else {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(books)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	app.out.message("Exported %d books to %s", len(books), *path)
+	return nil
+}
+
+// importBooks adds every book in a JSON or CSV file. All entries are checked before
+// any is added, so a malformed file imports nothing.
+func importBooks(app *app, args []string) error {
+	flags := flag.NewFlagSet("books import", flag.ExitOnError)
+	path := flags.String("file", "", "file to read, CSV if it ends in .csv and JSON otherwise")
+	flags.Parse(args)
+
+	if *path == "" {
+		return errors.New("-file is required")
+	}
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var books []models.Book
+	if isCSV(*path) {
+		books, err = readBooksCSV(file)
+	} else {
+		err = json.NewDecoder(file).Decode(&books)
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", *path, err)
+	}
+
+	for i, book := range books {
+		if strings.TrimSpace(book.Title) == "" || strings.TrimSpace(book.Author) == "" || book.Quantity < 0 {
+			return fmt.Errorf("book %d: title and author are required and quantity cannot be negative", i+1)
+		}
+	}
+
+	// Only the catalog fields are taken from the file
+	imported := make([]models.Book, len(books))
+	for i, book := range books {
+		imported[i] = models.Book{Title: book.Title, Author: book.Author, Quantity: book.Quantity}
+	}
+	if err := app.books.ImportBooks(imported); err != nil {
+		return fmt.Errorf("importing %s: %w; no books were imported", *path, err)
+	}
+
+	app.out.message("Imported %d books from %s", len(books), *path)
+	return nil
+}
+
+// printStats prints catalog statistics
+func printStats(app *app, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	flags.Parse(args)
+
+	stats, err := app.stats.GetCatalogStats()
+	if err != nil {
+		return err
+	}
+
+	rows := [][]string{
+		{"titles", strconv.FormatInt(stats.Titles, 10)},
+		{"copies", strconv.FormatInt(stats.Copies, 10)},
+		{"available", strconv.FormatInt(stats.Available, 10)},
+		{"active loans", strconv.FormatInt(stats.ActiveLoans, 10)},
+		{"overdue loans", strconv.FormatInt(stats.OverdueLoans, 10)},
+		{"waiting holds", strconv.FormatInt(stats.WaitingHolds, 10)},
+		{"ready holds", strconv.FormatInt(stats.ReadyHolds, 10)},
+	}
+	return app.out.print(stats, []string{"STAT", "VALUE"}, rows)
+}
+
+// bookRow formats a book as a row of csvHeader's columns
+func bookRow(book *models.Book) []string {
+	return []string{
+		strconv.FormatUint(uint64(book.ID), 10),
+		book.Title,
+		book.Author,
+		strconv.Itoa(book.Quantity),
+		strconv.Itoa(book.Available),
+	}
+}
+
+// isCSV reports whether a path names a CSV file
+func isCSV(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
+
+// writeBooksCSV writes books with a header row
+func writeBooksCSV(w io.Writer, books []models.Book) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, book := range books {
+		if err := writer.Write(bookRow(&book)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// readBooksCSV reads books from CSV with a header row naming at least the title,
+// author and quantity columns, in any order. Other columns are ignored.
+func readBooksCSV(r io.Reader) ([]models.Book, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"title", "author", "quantity"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing %s column", name)
+		}
+	}
+
+	var books []models.Book
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return books, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		quantity, err := strconv.Atoi(strings.TrimSpace(record[columns["quantity"]]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quantity %q", line, record[columns["quantity"]])
+		}
+		books = append(books, models.Book{
+			Title:    strings.TrimSpace(record[columns["title"]]),
+			Author:   strings.TrimSpace(record[columns["author"]]),
+			Quantity: quantity,
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Command bookctl runs operational tasks against the library database without going
+// through the HTTP API. It reads the same environment as the server.
+//
+// Usage:
+//
+//	bookctl [-output table|json] <command> [arguments]
+//
+// Run bookctl without arguments for the list of commands.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"example/go_api_tutorial/internal/config"
+	"example/go_api_tutorial/internal/database"
+	"example/go_api_tutorial/internal/repository/postgres"
+	"example/go_api_tutorial/internal/service"
+	"example/go_api_tutorial/internal/setup"
+	"gorm.io/gorm"
+)
+
+const usage = `Usage: bookctl [-output table|json] <command> [arguments]
+
+Commands:
+  user create -username NAME -email EMAIL [-role ROLE]   create an account
+  user set-role -username NAME -role ROLE                change an account's role
+  user reset-password -username NAME                     set a new password
+  books export [-file PATH]                              write the catalog as JSON, or CSV for a .csv file
+  books import -file PATH                                add the books in a JSON or CSV file
+  stats                                                  print catalog statistics
+  migrate up                                             apply pending migrations
+  migrate down [-steps N]                                revert the last N migrations, 1 by default
+  migrate status                                         list migrations and whether they are applied
+
+Passwords are read from BOOKCTL_PASSWORD, or from standard input when that is unset.
+`
+
+// command runs one bookctl command with the arguments after its name
+type command func(app *app, args []string) error
+
+var commands = map[string]command{
+	"user create":         createUser,
+	"user set-role":       setRole,
+	"user reset-password": resetPassword,
+	"books export":        exportBooks,
+	"books import":        importBooks,
+	"stats":               printStats,
+	"migrate up":          migrateUp,
+	"migrate down":        migrateDown,
+	"migrate status":      migrationStatus,
+}
+
+// app holds what the commands work with
+type app struct {
+	out   *printer
+	users *service.UserService
+	books *service.BookService
+	stats *service.StatsService
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("bookctl: ")
+
+	output := flag.String("output", "table", "output format, table or json")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	run, args, ok := lookupCommand(flag.Args())
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+	out, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal("Failed to load config: ", err)
+	}
+	if err := database.Connect(cfg); err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	defer database.Close()
+
+	ctl, err := newApp(cfg, database.GetDB(), out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := run(ctl, args); err != nil {
+		database.Close()
+		log.Fatal(err)
+	}
+}
+
+// lookupCommand finds the command named by the first one or two arguments
+func lookupCommand(args []string) (command, []string, bool) {
+	if len(args) >= 2 {
+		if run, ok := commands[args[0]+" "+args[1]]; ok {
+			return run, args[2:], true
+		}
+	}
+	if len(args) >= 1 {
+		if run, ok := commands[args[0]]; ok {
+			return run, args[1:], true
+		}
+	}
+	return nil, nil, false
+}
+
+// newApp builds the services the commands use, configured like the server's
+func newApp(cfg *config.Config, db *gorm.DB, out *printer) (*app, error) {
+	userCacheTTL, _ := time.ParseDuration(cfg.Security.UserCacheTTL)
+	holdPickupWindow, _ := time.ParseDuration(cfg.Library.HoldPickupWindow)
+
+	bookRepo := postgres.NewBookRepository(db)
+	loanRepo := postgres.NewLoanRepository(db)
+	holdRepo := postgres.NewHoldRepository(db)
+	attemptStore, err := setup.LoginAttemptStore(cfg, db)
+	if err != nil {
+		return nil, err
+	}
+	passwordPolicy, err := setup.PasswordPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	passwordHasher, err := setup.PasswordHasher(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	roleService := service.NewRoleService(postgres.NewRoleRepository(db), userCacheTTL)
+	// Only used to lift lockouts, so the policy doesn't matter
+	throttleService := service.NewThrottleService(attemptStore, service.LockoutPolicy{})
+	userService := service.NewUserService(postgres.NewUserRepository(db), roleService, throttleService, cfg.Security.PasswordHistorySize, userCacheTTL)
+	userService.WithPasswordPolicy(passwordPolicy).WithPasswordHasher(passwordHasher)
+	holdService := service.NewHoldService(holdRepo, bookRepo, holdPickupWindow)
+
+	return &app{
+		out:   out,
+		users: userService,
+		books: service.NewBookService(bookRepo, holdService),
+		stats: service.NewStatsService(bookRepo, loanRepo, holdRepo),
+	}, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"time"
+
+	"example/go_api_tutorial/internal/database"
+)
+
+// migrateUp applies pending migrations and seeds the built-in roles
+func migrateUp(app *app, args []string) error {
+	flags := flag.NewFlagSet("migrate up", flag.ExitOnError)
+	flags.Parse(args)
+
+	if err := database.Migrate(); err != nil {
+		return err
+	}
+	app.out.message("Database schema is up to date")
+	return nil
+}
+
+// migrateDown reverts the most recently applied migrations
+func migrateDown(app *app, args []string) error {
+	flags := flag.NewFlagSet("migrate down", flag.ExitOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert")
+	flags.Parse(args)
+
+	if *steps < 1 {
+		return errors.New("-steps must be at least 1")
+	}
+	if err := database.MigrateDown(*steps); err != nil {
+		return err
+	}
+	app.out.message("Reverted up to %d migrations", *steps)
+	return nil
+}
+
+// migrationStatus lists the migrations and whether they are applied
+func migrationStatus(app *app, args []string) error {
+	flags := flag.NewFlagSet("migrate status", flag.ExitOnError)
+	flags.Parse(args)
+
+	statuses, err := database.MigrationStatuses()
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(statuses))
+	for _, status := range statuses {
+		name, applied := status.Name, "pending"
+		if status.Unknown {
+			name = "(unknown to this build)"
+		}
+		if status.AppliedAt != nil {
+			applied = status.AppliedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{strconv.FormatInt(status.Version, 10), name, applied})
+	}
+	return app.out.print(statuses, []string{"VERSION", "NAME", "APPLIED"}, rows)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// createUser creates an account with a verified email address
+func createUser(app *app, args []string) error {
+	flags := flag.NewFlagSet("user create", flag.ExitOnError)
+	username := flags.String("username", "", "username")
+	email := flags.String("email", "", "email address")
+	role := flags.String("role", string(models.RoleUser), "role")
+	flags.Parse(args)
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	user, err := app.users.CreateUser(*username, *email, password, models.UserRole(*role))
+	if err != nil {
+		return err
+	}
+	return printUser(app, user)
+}
+
+// setRole changes an account's role
+func setRole(app *app, args []string) error {
+	flags := flag.NewFlagSet("user set-role", flag.ExitOnError)
+	username := flags.String("username", "", "username")
+	role := flags.String("role", "", "new role")
+	flags.Parse(args)
+
+	user, err := app.users.GetUserByUsername(*username)
+	if err != nil {
+		return err
+	}
+	if err := app.users.UpdateUserRole(user.ID, models.UserRole(*role)); err != nil {
+		return err
+	}
+	user.Role = models.UserRole(*role)
+	return printUser(app, user)
+}
+
+// resetPassword sets a new password, ending the account's sessions and lifting its lockout
+func resetPassword(app *app, args []string) error {
+	flags := flag.NewFlagSet("user reset-password", flag.ExitOnError)
+	username := flags.String("username", "", "username")
+	flags.Parse(args)
+
+	user, err := app.users.GetUserByUsername(*username)
+	if err != nil {
+		return err
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	if err := app.users.ResetPassword(user.ID, password, nil); err != nil {
+		return err
+	}
+	app.out.message("Password of %s reset", user.Username)
+	return nil
+}
+
+// printUser prints one user
+func printUser(app *app, user *models.User) error {
+	return app.out.print(user,
+		[]string{"ID", "USERNAME", "EMAIL", "ROLE"},
+		[][]string{{strconv.FormatUint(uint64(user.ID), 10), user.Username, user.Email, string(user.Role)}},
+	)
+}
+
+// readPassword reads a password from BOOKCTL_PASSWORD or standard input
+func readPassword() (string, error) {
+	if password := os.Getenv("BOOKCTL_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password is required")
+	}
+	return password, nil
+}
@@ -0,0 +1,196 @@
+// Command bootstrap-admin creates an admin account, or makes an existing account an
+// active admin again, directly in the database. It is meant for first setup and for
+// recovering when nobody can reach the admin routes anymore.
+//
+// Usage:
+//
+//	bootstrap-admin -username alice -email alice@example.com
+//	bootstrap-admin -username alice -reset-password
+//
+// The password is read from ADMIN_PASSWORD, or from standard input when that is unset.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"example/go_api_tutorial/internal/config"
+	"example/go_api_tutorial/internal/database"
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/repository/postgres"
+	"example/go_api_tutorial/internal/service"
+	"example/go_api_tutorial/internal/setup"
+	"example/go_api_tutorial/internal/utils"
+	"gorm.io/gorm"
+)
+
+func main() {
+	username := flag.String("username", "", "username of the admin to create or promote")
+	email := flag.String("email", "", "email address, required when creating the account")
+	resetPassword := flag.Bool("reset-password", false, "set a new password on an existing account")
+	flag.Parse()
+
+	if strings.TrimSpace(*username) == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+	if err := database.Connect(cfg); err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer database.Close()
+
+	// Creates the tables and built-in roles on a fresh database
+	if err := database.Migrate(); err != nil {
+		log.Fatal("Failed to run migrations:", err)
+	}
+
+	db := database.GetDB()
+	userRepo := postgres.NewUserRepository(db)
+	policy, err := setup.PasswordPolicy(cfg)
+	if err != nil {
+		log.Fatal("Failed to load breached password list:", err)
+	}
+	hasher, err := setup.PasswordHasher(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up password hashing:", err)
+	}
+	passwords := passwordReader{policy: policy, hasher: hasher}
+
+	user, err := userRepo.GetByUsername(strings.TrimSpace(*username))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		user, err = createAdmin(userRepo, passwords, strings.TrimSpace(*username), *email)
+		if err != nil {
+			log.Fatal("Failed to create admin: ", err)
+		}
+		fmt.Printf("Created admin %s (id %d)\n", user.Username, user.ID)
+		return
+	case err != nil:
+		log.Fatal("Failed to look up user: ", err)
+	}
+
+	if err := promoteAdmin(userRepo, passwords, user, *resetPassword, cfg.Security.PasswordHistorySize); err != nil {
+		log.Fatal("Failed to promote admin: ", err)
+	}
+
+	// Failed logins are only remembered across restarts in the postgres store
+	if cfg.Throttle.Store == "postgres" {
+		throttle := service.NewThrottleService(postgres.NewLoginAttemptStore(db), service.LockoutPolicy{})
+		if err := throttle.ResetAccount(user.ID); err != nil {
+			log.Fatal("Failed to lift login lockout: ", err)
+		}
+	}
+
+	fmt.Printf("%s (id %d) is an active admin\n", user.Username, user.ID)
+	fmt.Printf("Running servers pick up the change within USER_CACHE_TTL (%s); log in again to use it\n", cfg.Security.UserCacheTTL)
+}
+
+// createAdmin creates a new account with the admin role and a verified email address
+func createAdmin(userRepo interfaces.UserRepository, passwords passwordReader, username, email string) (*models.User, error) {
+	email = strings.TrimSpace(strings.ToLower(email))
+	if email == "" {
+		return nil, errors.New("-email is required to create an account")
+	}
+
+	exists, err := userRepo.ExistsByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("username belongs to a deleted account that is not anonymized yet")
+	}
+	exists, err = userRepo.ExistsByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("email already exists")
+	}
+
+	hash, err := passwords.read(username, email)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		Username:        username,
+		Email:           email,
+		EmailVerifiedAt: &now,
+		Password:        hash,
+		Role:            models.RoleAdmin,
+	}
+	if err := userRepo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// promoteAdmin gives an existing account the admin role, lifts a suspension and
+// optionally replaces the password. Tokens issued before a change stop working, so the
+// account logs in again with its new role.
+func promoteAdmin(userRepo interfaces.UserRepository, passwords passwordReader, user *models.User, resetPassword bool, historySize int) error {
+	changed := false
+	if user.Role != models.RoleAdmin {
+		if err := userRepo.UpdateRole(user.ID, models.RoleAdmin); err != nil {
+			return err
+		}
+		changed = true
+	}
+	if user.IsSuspended() {
+		if _, err := userRepo.Reactivate(user.ID); err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	if resetPassword {
+		hash, err := passwords.read(user.Username, user.Email)
+		if err != nil {
+			return err
+		}
+		// Also invalidates the account's tokens
+		return userRepo.ChangePassword(user.ID, hash, user.Password, historySize)
+	}
+	if changed {
+		return userRepo.InvalidateTokens(user.ID)
+	}
+	return nil
+}
+
+// passwordReader reads new passwords and hashes them like the server does
+type passwordReader struct {
+	policy utils.PasswordPolicy
+	hasher utils.PasswordHasher
+}
+
+// read reads a password from ADMIN_PASSWORD or standard input, checks it against
+// the password policy and returns its hash
+func (p passwordReader) read(username, email string) (string, error) {
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("reading password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if err := p.policy.Validate(password, username, email); err != nil {
+		return "", err
+	}
+	return p.hasher.Hash(password)
+}
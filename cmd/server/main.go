@@ -1,15 +1,22 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"example/go_api_tutorial/internal/config"
 	"example/go_api_tutorial/internal/database"
 	"example/go_api_tutorial/internal/handler"
+	"example/go_api_tutorial/internal/mailer"
 	"example/go_api_tutorial/internal/middleware"
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/oidc"
+	"example/go_api_tutorial/internal/repository/interfaces"
 	"example/go_api_tutorial/internal/repository/postgres"
 	"example/go_api_tutorial/internal/service"
+	"example/go_api_tutorial/internal/setup"
 	"example/go_api_tutorial/internal/utils"
 	"github.com/gin-gonic/gin"
 )
@@ -39,88 +46,440 @@ func main() {
 	
 	// Initialize JWT manager
 	expiresIn, _ := time.ParseDuration(cfg.JWT.ExpiresIn)
-	jwtManager := utils.NewJWTManager(cfg.JWT.Secret, expiresIn)
+	refreshExpiresIn, _ := time.ParseDuration(cfg.JWT.RefreshExpiresIn)
+	jwtManager, err := newJWTManager(cfg, expiresIn)
+	if err != nil {
+		log.Fatal("Failed to load JWT keys:", err)
+	}
+	jwtManager.WithAudience(cfg.JWT.Issuer, cfg.JWT.Audience)
+	loanPeriod, _ := time.ParseDuration(cfg.Library.LoanPeriod)
+	holdPickupWindow, _ := time.ParseDuration(cfg.Library.HoldPickupWindow)
+	holdExpiryInterval, _ := time.ParseDuration(cfg.Library.HoldExpiryInterval)
+	revocationSyncInterval, _ := time.ParseDuration(cfg.Security.RevocationSyncInterval)
+	userCacheTTL, _ := time.ParseDuration(cfg.Security.UserCacheTTL)
+	loginBackoffBase, _ := time.ParseDuration(cfg.Throttle.LoginBackoffBase)
+	loginLockoutDuration, _ := time.ParseDuration(cfg.Throttle.LoginLockoutDuration)
+	loginAttemptWindow, _ := time.ParseDuration(cfg.Throttle.LoginAttemptWindow)
+	loginIPWindow, _ := time.ParseDuration(cfg.Throttle.LoginIPWindow)
+	registerIPWindow, _ := time.ParseDuration(cfg.Throttle.RegisterIPWindow)
+	throttleCleanupInterval, _ := time.ParseDuration(cfg.Throttle.CleanupInterval)
+	twoFactorChallengeTTL, _ := time.ParseDuration(cfg.TwoFactor.ChallengeTTL)
+	emailVerificationTTL, _ := time.ParseDuration(cfg.Security.EmailVerificationTTL)
+	passwordResetTTL, _ := time.ParseDuration(cfg.Security.PasswordResetTTL)
+	apiKeyDefaultTTL, _ := time.ParseDuration(cfg.Security.APIKeyDefaultTTL)
+	apiKeyMaxTTL, _ := time.ParseDuration(cfg.Security.APIKeyMaxTTL)
+	impersonationTTL, _ := time.ParseDuration(cfg.Security.ImpersonationTTL)
+	accountDeletionGracePeriod, _ := time.ParseDuration(cfg.Security.AccountDeletionGracePeriod)
+	accountAnonymizeInterval, _ := time.ParseDuration(cfg.Security.AccountAnonymizeInterval)
+	exportTTL, _ := time.ParseDuration(cfg.Export.TTL)
+	invitationTTL, _ := time.ParseDuration(cfg.Registration.InvitationTTL)
+	exportCleanupInterval, _ := time.ParseDuration(cfg.Export.CleanupInterval)
+	exportCooldown, _ := time.ParseDuration(cfg.Export.Cooldown)
+	// Revoking a token only denies it for an access token lifetime
+	if impersonationTTL > expiresIn {
+		impersonationTTL = expiresIn
+	}
+	
+	// Initialize mailer
+	mail, err := newMailer(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up mailer:", err)
+	}
 	
 	// Initialize repositories
 	bookRepo := postgres.NewBookRepository(db)
 	userRepo := postgres.NewUserRepository(db)
+	loanRepo := postgres.NewLoanRepository(db)
+	holdRepo := postgres.NewHoldRepository(db)
+	sessionRepo := postgres.NewSessionRepository(db)
+	revokedTokenRepo := postgres.NewRevokedTokenRepository(db)
+	roleRepo := postgres.NewRoleRepository(db)
+	passwordResetRepo := postgres.NewPasswordResetRepository(db)
+	apiKeyRepo := postgres.NewAPIKeyRepository(db)
+	oidcLoginRepo := postgres.NewOIDCLoginRepository(db)
+	auditEventRepo := postgres.NewAuditEventRepository(db)
+	dataExportRepo := postgres.NewDataExportRepository(db)
+	invitationRepo := postgres.NewInvitationRepository(db)
+	attemptStore, err := setup.LoginAttemptStore(cfg, db)
+	if err != nil {
+		log.Fatal("Failed to set up throttling:", err)
+	}
 	
 	// Initialize services
-	bookService := service.NewBookService(bookRepo)
-	userService := service.NewUserService(userRepo)
+	roleService := service.NewRoleService(roleRepo, userCacheTTL)
+	throttleService := service.NewThrottleService(attemptStore, service.LockoutPolicy{
+		MaxAttempts:     cfg.Throttle.LoginMaxAttempts,
+		BackoffAfter:    cfg.Throttle.LoginBackoffAfter,
+		BackoffBase:     loginBackoffBase,
+		LockoutDuration: loginLockoutDuration,
+		Window:          loginAttemptWindow,
+	})
+	holdService := service.NewHoldService(holdRepo, bookRepo, holdPickupWindow)
+	bookService := service.NewBookService(bookRepo, holdService)
+	userService := service.NewUserService(userRepo, roleService, throttleService, cfg.Security.PasswordHistorySize, userCacheTTL)
+	userService.RequireVerifiedEmail(cfg.Security.EmailVerification)
+	passwordPolicy, err := setup.PasswordPolicy(cfg)
+	if err != nil {
+		log.Fatal("Failed to load breached password list:", err)
+	}
+	userService.WithPasswordPolicy(passwordPolicy)
+	passwordHasher, err := setup.PasswordHasher(cfg)
+	if err != nil {
+		log.Fatal("Failed to set up password hashing:", err)
+	}
+	userService.WithPasswordHasher(passwordHasher)
+	emailVerificationService := service.NewEmailVerificationService(userRepo, userService, mail, jwtManager, cfg.Server.PublicURL, emailVerificationTTL)
+	loanService := service.NewLoanService(loanRepo, bookRepo, holdService, loanPeriod)
+	revocationService := service.NewRevocationService(revokedTokenRepo, expiresIn)
+	twoFactorService := service.NewTwoFactorService(userRepo, throttleService, jwtManager, cfg.TwoFactor.Issuer, twoFactorChallengeTTL, twoFactorRequiredRoles(cfg))
+	sessionService := service.NewSessionService(sessionRepo, userRepo, revocationService, roleService, jwtManager, refreshExpiresIn)
+	passwordResetService := service.NewPasswordResetService(passwordResetRepo, userRepo, userService, sessionService, mail, cfg.Security.PasswordResetURL, passwordResetTTL)
+	accountService := service.NewAccountService(userRepo, userService, sessionService, loanService, holdService, accountDeletionGracePeriod)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userService, roleService, apiKeyDefaultTTL, apiKeyMaxTTL)
+	auditService := service.NewAuditService(auditEventRepo)
+	invitationService := service.NewInvitationService(invitationRepo, userService, roleService, cfg.Registration.Mode, invitationTTL)
+	dataExportService := service.NewDataExportService(dataExportRepo, userRepo, loanRepo, holdRepo, sessionRepo, auditEventRepo, cfg.Export.Dir, exportTTL, exportCooldown)
+	impersonationService := service.NewImpersonationService(userService, roleService, jwtManager, auditService, impersonationTTL)
+	oidcService, err := newOIDCService(cfg, oidcLoginRepo, userRepo, userService, roleService)
+	if err != nil {
+		log.Fatal("Failed to set up OIDC sign-in:", err)
+	}
 	
 	// Initialize handlers
 	bookHandler := handler.NewBookHandler(bookService)
-	authHandler := handler.NewAuthHandler(userService, jwtManager)
-	userHandler := handler.NewUserHandler(userService)
+	authHandler := handler.NewAuthHandler(userService, sessionService, twoFactorService, emailVerificationService, invitationService)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService, sessionService)
+	passwordResetHandler := handler.NewPasswordResetHandler(passwordResetService)
+	accountHandler := handler.NewAccountHandler(accountService, sessionService)
+	dataExportHandler := handler.NewDataExportHandler(dataExportService)
+	invitationHandler := handler.NewInvitationHandler(invitationService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	impersonationHandler := handler.NewImpersonationHandler(impersonationService)
+	userHandler := handler.NewUserHandler(userService, sessionService, apiKeyService, accountService)
+	loanHandler := handler.NewLoanHandler(loanService, roleService)
+	holdHandler := handler.NewHoldHandler(holdService, roleService)
+	roleHandler := handler.NewRoleHandler(roleService)
+	jwksHandler := handler.NewJWKSHandler(jwtManager)
+
+	// Load the token deny-list and keep it in sync with other instances
+	if err := revocationService.Sync(); err != nil {
+		log.Fatal("Failed to load token deny-list:", err)
+	}
+	stopRevocationSync := revocationService.StartSync(revocationSyncInterval)
+	defer stopRevocationSync()
+
+	// Lapse uncollected holds in the background
+	stopHoldExpirer := holdService.StartExpirer(holdExpiryInterval)
+	defer stopHoldExpirer()
 
-	// Initialize Gin router
-	router := gin.Default()
+	// Erase deleted accounts once their grace period is over
+	stopAnonymizer := accountService.StartAnonymizer(accountAnonymizeInterval)
+	defer stopAnonymizer()
+
+	// Delete data exports once they can no longer be downloaded
+	stopExportCleanup := dataExportService.StartCleanup(exportCleanupInterval)
+	defer stopExportCleanup()
+
+	// Send password reset emails in the background
+	stopResetWorker := passwordResetService.StartWorker()
+	defer stopResetWorker()
+
+	// Drop attempt counters whose window has ended
+	stopThrottleCleanup := throttleService.StartCleanup(throttleCleanupInterval)
+	defer stopThrottleCleanup()
+
+	// Initialize Gin router. Client IPs, used for rate limits, sessions and audit events,
+	// are only taken from forwarding headers set by a trusted proxy.
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	router.Use(gin.Logger(), gin.Recovery())
+
+	// Audit what support staff do while acting as a user
+	router.Use(middleware.AuditImpersonation(auditService))
 
 	
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok", "message": "Book Dictionary API is running"})
 	})
 
+	// Public keys for services verifying our tokens
+	router.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
+	// Validates the access token or API key and reconciles it with server-side state
+	authMiddleware := middleware.AuthMiddleware(jwtManager, apiKeyService, revocationService, userService, twoFactorService, impersonationService)
+
+	// Per-IP limits on credential guessing and sign-ups
+	loginLimit := middleware.RateLimit(throttleService.RateLimit("login", cfg.Throttle.LoginIPLimit, loginIPWindow))
+	registerLimit := middleware.RateLimit(throttleService.RateLimit("register", cfg.Throttle.RegisterIPLimit, registerIPWindow))
+	resendLimit := middleware.RateLimit(throttleService.RateLimit("resend-verification", cfg.Throttle.RegisterIPLimit, registerIPWindow))
+	forgotPasswordLimit := middleware.RateLimit(throttleService.RateLimit("forgot-password", cfg.Throttle.RegisterIPLimit, registerIPWindow))
+
 	// Authentication routes
 	authRoutes := router.Group("/auth")
 	{
-		authRoutes.POST("/register", authHandler.Register)          
-		authRoutes.POST("/login", authHandler.Login)               
+		authRoutes.POST("/register", registerLimit, authHandler.Register)
+		authRoutes.POST("/login", loginLimit, authHandler.Login)
+		authRoutes.POST("/2fa/challenge", loginLimit, twoFactorHandler.Challenge)
+		authRoutes.GET("/verify-email", authHandler.VerifyEmail)
+		authRoutes.POST("/verify-email/resend", resendLimit, authHandler.ResendVerification)
+		authRoutes.POST("/forgot-password", forgotPasswordLimit, passwordResetHandler.ForgotPassword)
+		authRoutes.POST("/reset-password", loginLimit, passwordResetHandler.ResetPassword)
 		authRoutes.POST("/refresh", authHandler.RefreshToken)       
+		if oidcService != nil {
+			oidcHandler := handler.NewOIDCHandler(oidcService, sessionService, twoFactorService, strings.HasPrefix(cfg.Server.PublicURL, "https://"))
+			authRoutes.GET("/oidc/login", loginLimit, oidcHandler.Login)
+			authRoutes.GET("/oidc/callback", loginLimit, oidcHandler.Callback)
+		}
 		
 		// Protected auth routes (require authentication)
-		protected := authRoutes.Group("", middleware.AuthMiddleware(jwtManager))
+		protected := authRoutes.Group("", authMiddleware)
 		{
 			protected.GET("/profile", authHandler.GetProfile)           
-			protected.POST("/change-password", authHandler.ChangePassword) 
+
+			// Account management needs the user's own login, not an API key
+			session := protected.Group("", middleware.RequireSession())
+			{
+				session.PATCH("/profile", authHandler.UpdateProfile)
+				session.DELETE("/profile", accountHandler.DeleteAccount)
+				session.POST("/profile/export", dataExportHandler.RequestExport)
+				session.GET("/profile/export/:id", dataExportHandler.DownloadExport)
+				session.POST("/change-password", authHandler.ChangePassword) 
+				session.POST("/logout", authHandler.Logout)
+				session.GET("/sessions", authHandler.GetSessions)
+				session.DELETE("/sessions/:id", authHandler.RevokeSession)
+				session.POST("/2fa/setup", twoFactorHandler.Setup)
+				session.POST("/2fa/verify", twoFactorHandler.Verify)
+				session.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+				session.GET("/api-keys", apiKeyHandler.GetAPIKeys)
+				session.DELETE("/api-keys/:id", apiKeyHandler.RevokeAPIKey)
+			}
 		}
 	}
 
+	// Shorthand for route-level permission checks
+	can := func(permission string) gin.HandlerFunc {
+		return middleware.RequirePermission(roleService, permission)
+	}
+
 	// Book routes (require authentication)
-	bookRoutes := router.Group("/books", middleware.AuthMiddleware(jwtManager))
+	bookRoutes := router.Group("/books", authMiddleware)
 	{
-		bookRoutes.GET("", bookHandler.GetBooks)                    	
-		bookRoutes.GET("/:id", bookHandler.GetBookByID)             
-		
-		// Admin-only book routes
-		adminBookRoutes := bookRoutes.Group("", middleware.AdminMiddleware())
-		{
-			adminBookRoutes.POST("", bookHandler.CreateBook)                 
-			adminBookRoutes.PUT("/:id", bookHandler.UpdateBook)              
-			adminBookRoutes.DELETE("/:id", bookHandler.DeleteBook)           
-			adminBookRoutes.PATCH("/:id/quantity", bookHandler.UpdateBookQuantity) 
-		}
+		bookRoutes.GET("", can(models.PermissionBookRead), bookHandler.GetBooks)
+		bookRoutes.GET("/:id", can(models.PermissionBookRead), bookHandler.GetBookByID)
+		bookRoutes.POST("/:id/checkout", can(models.PermissionLoanCreate), loanHandler.Checkout)
+		bookRoutes.POST("/:id/holds", can(models.PermissionLoanCreate), holdHandler.PlaceHold)
+		bookRoutes.POST("", can(models.PermissionBookCreate), bookHandler.CreateBook)
+		bookRoutes.PUT("/:id", can(models.PermissionBookUpdate), bookHandler.UpdateBook)
+		bookRoutes.DELETE("/:id", can(models.PermissionBookDelete), bookHandler.DeleteBook)
+		bookRoutes.PATCH("/:id/quantity", can(models.PermissionInventoryAdjust), bookHandler.UpdateBookQuantity)
+	}
+
+	// Loan routes (require authentication)
+	loanRoutes := router.Group("/loans", authMiddleware, can(models.PermissionLoanCreate))
+	{
+		loanRoutes.GET("", loanHandler.GetMyLoans)
+		loanRoutes.POST("/:id/return", loanHandler.ReturnLoan)
+	}
+
+	// Hold routes (require authentication)
+	holdRoutes := router.Group("/holds", authMiddleware, can(models.PermissionLoanCreate))
+	{
+		holdRoutes.GET("", holdHandler.GetMyHolds)
+		holdRoutes.DELETE("/:id", holdHandler.CancelHold)
+	}
+
+	// User management routes
+	userRoutes := router.Group("/users", authMiddleware)
+	{
+		userRoutes.GET("", can(models.PermissionUserRead), userHandler.ListUsers)
+		userRoutes.GET("/:id", can(models.PermissionUserRead), userHandler.GetUserByID)
+		userRoutes.PATCH("/:id/role", can(models.PermissionUserRoleUpdate), userHandler.UpdateUserRole)
+		userRoutes.DELETE("/:id/sessions", can(models.PermissionUserSessionRevoke), userHandler.RevokeUserSessions)
+		userRoutes.POST("/:id/unlock", can(models.PermissionUserUnlock), userHandler.UnlockUser)
+		userRoutes.POST("/:id/suspend", can(models.PermissionUserSuspend), userHandler.SuspendUser)
+		userRoutes.POST("/:id/reactivate", can(models.PermissionUserSuspend), userHandler.ReactivateUser)
+		userRoutes.DELETE("/:id", can(models.PermissionUserDelete), userHandler.DeleteUser)
+		userRoutes.POST("/:id/impersonate", middleware.RequireSession(), can(models.PermissionUserImpersonate), impersonationHandler.Impersonate)
+	}
+
+	// Role management routes
+	roleRoutes := router.Group("/roles", authMiddleware, can(models.PermissionRoleManage))
+	{
+		roleRoutes.GET("", roleHandler.GetRoles)
+		roleRoutes.GET("/permissions", roleHandler.GetPermissions)
+		roleRoutes.POST("", roleHandler.CreateRole)
+		roleRoutes.PUT("/:name/permissions", roleHandler.SetRolePermissions)
+		roleRoutes.DELETE("/:name", roleHandler.DeleteRole)
 	}
 
-	// User management routes (admin only)
-	userRoutes := router.Group("/users", middleware.AuthMiddleware(jwtManager), middleware.AdminMiddleware())
+	// Registration invitation routes
+	invitationRoutes := router.Group("/invitations", authMiddleware, can(models.PermissionInvitationManage))
 	{
-		userRoutes.GET("", userHandler.GetAllUsers)                 
-		userRoutes.GET("/:id", userHandler.GetUserByID)             
-		userRoutes.PATCH("/:id/role", userHandler.UpdateUserRole)   
+		invitationRoutes.POST("", invitationHandler.CreateInvitation)
+		invitationRoutes.GET("", invitationHandler.GetInvitations)
+		invitationRoutes.DELETE("/:id", invitationHandler.RevokeInvitation)
 	}
 
 	// Start server
 	log.Printf("Server starting on %s", cfg.GetServerAddress())
 	log.Println("Available endpoints:")
 	log.Println("  GET    /health")
+	log.Println("  GET    /.well-known/jwks.json")
 	log.Println("  POST   /auth/register")
 	log.Println("  POST   /auth/login")
 	log.Println("  POST   /auth/refresh")
+	log.Println("  POST   /auth/2fa/challenge")
+	log.Println("  GET    /auth/verify-email?token=")
+	log.Println("  POST   /auth/verify-email/resend")
+	log.Println("  POST   /auth/forgot-password")
+	log.Println("  POST   /auth/reset-password")
+	if oidcService != nil {
+		log.Println("  GET    /auth/oidc/login")
+		log.Println("  GET    /auth/oidc/callback")
+	}
 	log.Println("  GET    /auth/profile (auth required)")
+	log.Println("  PATCH  /auth/profile (auth required)")
+	log.Println("  DELETE /auth/profile (auth required)")
+	log.Println("  POST   /auth/profile/export (auth required)")
+	log.Println("  GET    /auth/profile/export/:id (auth required)")
 	log.Println("  POST   /auth/change-password (auth required)")
+	log.Println("  POST   /auth/logout (auth required)")
+	log.Println("  GET    /auth/sessions (auth required)")
+	log.Println("  DELETE /auth/sessions/:id (auth required)")
+	log.Println("  POST   /auth/2fa/setup (auth required)")
+	log.Println("  POST   /auth/2fa/verify (auth required)")
+	log.Println("  POST   /auth/api-keys (auth required)")
+	log.Println("  GET    /auth/api-keys (auth required)")
+	log.Println("  DELETE /auth/api-keys/:id (auth required)")
 	log.Println("  GET    /books (auth required)")
 	log.Println("  GET    /books/:id (auth required)")
-	log.Println("  POST   /books (admin only)")
-	log.Println("  PUT    /books/:id (admin only)")
-	log.Println("  DELETE /books/:id (admin only)")
-	log.Println("  PATCH  /books/:id/quantity (admin only)")
-	log.Println("  GET    /users (admin only)")
-	log.Println("  GET    /users/:id (admin only)")
-	log.Println("  PATCH  /users/:id/role (admin only)")
+	log.Println("  POST   /books/:id/checkout (auth required)")
+	log.Println("  POST   /books/:id/holds (auth required)")
+	log.Println("  POST   /books (book.create)")
+	log.Println("  PUT    /books/:id (book.update)")
+	log.Println("  DELETE /books/:id (book.delete)")
+	log.Println("  PATCH  /books/:id/quantity (inventory.adjust)")
+	log.Println("  GET    /loans (auth required)")
+	log.Println("  POST   /loans/:id/return (auth required)")
+	log.Println("  GET    /holds (auth required)")
+	log.Println("  DELETE /holds/:id (auth required)")
+	log.Println("  GET    /users (user.read)")
+	log.Println("  GET    /users/:id (user.read)")
+	log.Println("  PATCH  /users/:id/role (user.role.update)")
+	log.Println("  DELETE /users/:id/sessions (user.session.revoke)")
+	log.Println("  POST   /users/:id/unlock (user.unlock)")
+	log.Println("  POST   /users/:id/suspend (user.suspend)")
+	log.Println("  POST   /users/:id/reactivate (user.suspend)")
+	log.Println("  DELETE /users/:id (user.delete)")
+	log.Println("  POST   /users/:id/impersonate (user.impersonate)")
+	log.Println("  GET    /roles (role.manage)")
+	log.Println("  GET    /roles/permissions (role.manage)")
+	log.Println("  POST   /roles (role.manage)")
+	log.Println("  PUT    /roles/:name/permissions (role.manage)")
+	log.Println("  DELETE /roles/:name (role.manage)")
+	log.Println("  POST   /invitations (invitation.manage)")
+	log.Println("  GET    /invitations (invitation.manage)")
+	log.Println("  DELETE /invitations/:id (invitation.manage)")
 	
 	if err := router.Run(cfg.GetServerAddress()); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
-}
\ No newline at end of file
+}
+
+// newJWTManager signs with the configured PEM keys, or with the shared secret when none are set
+func newJWTManager(cfg *config.Config, expiresIn time.Duration) (*utils.JWTManager, error) {
+	if len(cfg.JWT.Keys) == 0 {
+		return utils.NewJWTManager(cfg.JWT.Secret, expiresIn), nil
+	}
+
+	keys := make([]*utils.SigningKey, 0, len(cfg.JWT.Keys))
+	for _, keyCfg := range cfg.JWT.Keys {
+		var activeFrom time.Time
+		if keyCfg.ActiveFrom != "" {
+			parsed, err := time.Parse(time.RFC3339, keyCfg.ActiveFrom)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: invalid activation time: %w", keyCfg.ID, err)
+			}
+			activeFrom = parsed
+		}
+
+		key, err := utils.LoadSigningKey(keyCfg.ID, keyCfg.Path, activeFrom)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	gracePeriod, _ := time.ParseDuration(cfg.JWT.KeyGracePeriod)
+	return utils.NewJWTManagerWithKeys(keys, gracePeriod, expiresIn)
+}
+
+// newMailer returns the configured mailer
+func newMailer(cfg *config.Config) (mailer.Mailer, error) {
+	switch cfg.Mail.Driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.Mail.From, cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.Mail.From, cfg.Mail.FileDir)
+	case "log":
+		return mailer.NewLogMailer(cfg.Mail.From), nil
+	case "":
+		return nil, fmt.Errorf("MAIL_DRIVER is required: smtp, or file or log for development")
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q, expected smtp, file or log", cfg.Mail.Driver)
+	}
+}
+
+// newOIDCService returns the identity provider sign-in service, or nil when OIDC_ISSUER isn't set
+func newOIDCService(cfg *config.Config, loginRepo interfaces.OIDCLoginRepository, userRepo interfaces.UserRepository, userService *service.UserService, roleService *service.RoleService) (*service.OIDCService, error) {
+	if cfg.OIDC.Issuer == "" {
+		return nil, nil
+	}
+
+	defaultRole := models.UserRole(cfg.OIDC.DefaultRole)
+	roles := []models.UserRole{defaultRole}
+	var groupRoles []service.GroupRole
+	for _, entry := range cfg.OIDC.RoleMapping {
+		group, role, ok := strings.Cut(entry, "=")
+		if !ok || group == "" || role == "" {
+			return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING entry %q, expected group=role", entry)
+		}
+		groupRoles = append(groupRoles, service.GroupRole{Group: group, Role: models.UserRole(role)})
+		roles = append(roles, models.UserRole(role))
+	}
+
+	for _, role := range roles {
+		exists, err := roleService.RoleExists(role)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("unknown role %q", role)
+		}
+	}
+
+	loginTTL, _ := time.ParseDuration(cfg.OIDC.LoginTTL)
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       cfg.OIDC.Issuer,
+		ClientID:     cfg.OIDC.ClientID,
+		ClientSecret: cfg.OIDC.ClientSecret,
+		RedirectURL:  cfg.OIDC.RedirectURL,
+		Scopes:       cfg.OIDC.Scopes,
+		GroupsClaim:  cfg.OIDC.GroupsClaim,
+	})
+	oidcService := service.NewOIDCService(provider, loginRepo, userRepo, userService, groupRoles, defaultRole, loginTTL)
+	// New accounts come from registration only when anyone may register
+	oidcService.WithProvisioning(cfg.Registration.Mode == service.RegistrationOpen)
+	return oidcService, nil
+}
+
+// twoFactorRequiredRoles returns the roles configured to require a second factor
+func twoFactorRequiredRoles(cfg *config.Config) []models.UserRole {
+	roles := make([]models.UserRole, 0, len(cfg.TwoFactor.RequiredRoles))
+	for _, role := range cfg.TwoFactor.RequiredRoles {
+		roles = append(roles, models.UserRole(role))
+	}
+	return roles
+}
@@ -3,14 +3,25 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
-	JWT      JWTConfig
+	Database     DatabaseConfig
+	Server       ServerConfig
+	JWT          JWTConfig
+	Library      LibraryConfig
+	Security     SecurityConfig
+	Throttle     ThrottleConfig
+	TwoFactor    TwoFactorConfig
+	Mail         MailConfig
+	Password     PasswordConfig
+	OIDC         OIDCConfig
+	Export       ExportConfig
+	Registration RegistrationConfig
 }
 
 type DatabaseConfig struct {
@@ -25,11 +36,140 @@ type DatabaseConfig struct {
 type ServerConfig struct {
 	Host string
 	Port string
+	// PublicURL is where users' browsers reach the API, used for links in emails
+	PublicURL string
+	// TrustedProxies are the addresses or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers are believed; none by default
+	TrustedProxies []string
 }
 
 type JWTConfig struct {
-	Secret    string
-	ExpiresIn string
+	Secret           string
+	ExpiresIn        string
+	RefreshExpiresIn string
+	// Keys switches signing from Secret (HS256) to RSA/Ed25519 PEM keys
+	Keys           []JWTKeyConfig
+	KeyGracePeriod string
+	Issuer         string
+	Audience       []string
+}
+
+// JWTKeyConfig describes one signing key, configured as kid=path[@RFC3339 activation time]
+type JWTKeyConfig struct {
+	ID         string
+	Path       string
+	ActiveFrom string // empty means active immediately
+}
+
+type LibraryConfig struct {
+	LoanPeriod         string
+	HoldPickupWindow   string
+	HoldExpiryInterval string
+}
+
+type SecurityConfig struct {
+	PasswordHistorySize    int
+	RevocationSyncInterval string
+	UserCacheTTL           string
+	// EmailVerification is what unverified users can't do: "off", "login" or "write"
+	EmailVerification    string
+	EmailVerificationTTL string
+	// PasswordResetURL is the page reset emails link to, with the token appended as ?token=
+	PasswordResetURL string
+	PasswordResetTTL string
+	// API keys expire after APIKeyDefaultTTL unless created with another expiry up to APIKeyMaxTTL
+	APIKeyDefaultTTL string
+	APIKeyMaxTTL     string
+	// ImpersonationTTL is how long a support token for acting as a user lasts
+	ImpersonationTTL string
+	// Deleted accounts are anonymized AccountDeletionGracePeriod after deletion,
+	// checked every AccountAnonymizeInterval
+	AccountDeletionGracePeriod string
+	AccountAnonymizeInterval   string
+}
+
+type ThrottleConfig struct {
+	// Store is where attempts are counted: "memory" for a single instance,
+	// "postgres" to share limits between instances
+	Store                string
+	LoginMaxAttempts     int
+	LoginBackoffAfter    int
+	LoginBackoffBase     string
+	LoginLockoutDuration string
+	LoginAttemptWindow   string
+	LoginIPLimit         int
+	LoginIPWindow        string
+	RegisterIPLimit      int
+	RegisterIPWindow     string
+	CleanupInterval      string
+}
+
+type TwoFactorConfig struct {
+	Issuer       string // name shown in authenticator apps
+	ChallengeTTL string
+	// RequiredRoles lists roles that must log in with a second factor
+	RequiredRoles []string
+}
+
+type OIDCConfig struct {
+	// Issuer is the identity provider's issuer URL; empty turns OIDC sign-in off
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL defaults to PUBLIC_URL + /auth/oidc/callback
+	RedirectURL string
+	Scopes      []string
+	GroupsClaim string
+	// RoleMapping lists group=role pairs, the first group the user is in decides their role
+	RoleMapping []string
+	DefaultRole string
+	LoginTTL    string
+}
+
+type MailConfig struct {
+	// Driver selects how emails are sent: "smtp", "file" (one .eml per email in FileDir) or
+	// "log"; there is no default, so a deployment can't silently skip sending
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string
+}
+
+type PasswordConfig struct {
+	MinLength        int
+	MaxLength        int
+	RequireUpper     bool
+	RequireLower     bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	DisallowUserInfo bool
+	MaxRepeated      int
+	// BreachedDir holds breached password hashes split by SHA-1 prefix; empty disables the check
+	BreachedDir string
+	// Hasher is the algorithm new passwords are hashed with: "argon2id" or "bcrypt"
+	Hasher            string
+	Argon2Memory      int // in KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+	BcryptCost        int
+}
+
+type RegistrationConfig struct {
+	// Mode is who may register: "open", "invite" (with an invitation code) or "closed"
+	Mode          string
+	InvitationTTL string
+}
+
+type ExportConfig struct {
+	// Dir holds the generated archives; share it between instances so any of them can serve a download
+	Dir             string
+	TTL             string
+	CleanupInterval string
+	// Cooldown is how long after requesting an export a user has to wait to request another
+	Cooldown string
 }
 
 // LoadConfig loads configuration from environment variables
@@ -39,6 +179,11 @@ func LoadConfig() (*Config, error) {
 		fmt.Println("No .env file found, using system environment variables")
 	}
 
+	jwtKeys, err := parseJWTKeys(getEnv("JWT_KEYS", ""))
+	if err != nil {
+		return nil, err
+	}
+
 	config := &Config{
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -49,13 +194,135 @@ func LoadConfig() (*Config, error) {
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		},
 		Server: ServerConfig{
-			Host: getEnv("SERVER_HOST", "localhost"),
-			Port: getEnv("SERVER_PORT", "8080"),
+			Host:           getEnv("SERVER_HOST", "localhost"),
+			Port:           getEnv("SERVER_PORT", "8080"),
+			PublicURL:      getEnv("PUBLIC_URL", "http://localhost:8080"),
+			TrustedProxies: getEnvList("TRUSTED_PROXIES", ""),
 		},
 		JWT: JWTConfig{
-			Secret:    getEnv("JWT_SECRET", "your-secret-key"),
-			ExpiresIn: getEnv("JWT_EXPIRES_IN", "24h"),
+			Secret:           getEnv("JWT_SECRET", "your-secret-key"),
+			ExpiresIn:        getEnv("JWT_EXPIRES_IN", "15m"),
+			RefreshExpiresIn: getEnv("JWT_REFRESH_EXPIRES_IN", "720h"),
+			Keys:             jwtKeys,
+			KeyGracePeriod:   getEnv("JWT_KEY_GRACE_PERIOD", "24h"),
+			Issuer:           getEnv("JWT_ISSUER", "book-dictionary-api"),
+			Audience:         getEnvList("JWT_AUDIENCE", "book-dictionary-api"),
+		},
+		Library: LibraryConfig{
+			LoanPeriod:         getEnv("LOAN_PERIOD", "336h"),
+			HoldPickupWindow:   getEnv("HOLD_PICKUP_WINDOW", "48h"),
+			HoldExpiryInterval: getEnv("HOLD_EXPIRY_INTERVAL", "1m"),
+		},
+		Security: SecurityConfig{
+			PasswordHistorySize:        getEnvInt("PASSWORD_HISTORY_SIZE", 5),
+			RevocationSyncInterval:     getEnv("TOKEN_REVOCATION_SYNC_INTERVAL", "30s"),
+			UserCacheTTL:               getEnv("USER_CACHE_TTL", "10s"),
+			EmailVerification:          getEnv("EMAIL_VERIFICATION", "off"),
+			EmailVerificationTTL:       getEnv("EMAIL_VERIFICATION_TTL", "24h"),
+			PasswordResetURL:           getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+			PasswordResetTTL:           getEnv("PASSWORD_RESET_TTL", "1h"),
+			APIKeyDefaultTTL:           getEnv("API_KEY_DEFAULT_TTL", "2160h"),
+			APIKeyMaxTTL:               getEnv("API_KEY_MAX_TTL", "8760h"),
+			ImpersonationTTL:           getEnv("IMPERSONATION_TTL", "15m"),
+			AccountDeletionGracePeriod: getEnv("ACCOUNT_DELETION_GRACE_PERIOD", "720h"),
+			AccountAnonymizeInterval:   getEnv("ACCOUNT_ANONYMIZE_INTERVAL", "1h"),
+		},
+		Throttle: ThrottleConfig{
+			Store:                getEnv("THROTTLE_STORE", "memory"),
+			LoginMaxAttempts:     getEnvInt("LOGIN_MAX_ATTEMPTS", 10),
+			LoginBackoffAfter:    getEnvInt("LOGIN_BACKOFF_AFTER", 3),
+			LoginBackoffBase:     getEnv("LOGIN_BACKOFF_BASE", "1s"),
+			LoginLockoutDuration: getEnv("LOGIN_LOCKOUT_DURATION", "15m"),
+			LoginAttemptWindow:   getEnv("LOGIN_ATTEMPT_WINDOW", "15m"),
+			LoginIPLimit:         getEnvInt("LOGIN_IP_LIMIT", 20),
+			LoginIPWindow:        getEnv("LOGIN_IP_WINDOW", "1m"),
+			RegisterIPLimit:      getEnvInt("REGISTER_IP_LIMIT", 5),
+			RegisterIPWindow:     getEnv("REGISTER_IP_WINDOW", "1h"),
+			CleanupInterval:      getEnv("THROTTLE_CLEANUP_INTERVAL", "5m"),
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer:        getEnv("TOTP_ISSUER", "Book Dictionary"),
+			ChallengeTTL:  getEnv("TWO_FACTOR_CHALLENGE_TTL", "5m"),
+			RequiredRoles: getEnvList("TWO_FACTOR_REQUIRED_ROLES", ""),
+		},
+		OIDC: OIDCConfig{
+			Issuer:       getEnv("OIDC_ISSUER", ""),
+			ClientID:     getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+			Scopes:       getEnvList("OIDC_SCOPES", "openid,email,profile"),
+			GroupsClaim:  getEnv("OIDC_GROUPS_CLAIM", "groups"),
+			RoleMapping:  getEnvList("OIDC_ROLE_MAPPING", ""),
+			DefaultRole:  getEnv("OIDC_DEFAULT_ROLE", "user"),
+			LoginTTL:     getEnv("OIDC_LOGIN_TTL", "10m"),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", ""),
+			From:         getEnv("MAIL_FROM", "Book Dictionary <no-reply@localhost>"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnv("SMTP_PORT", "1025"),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			FileDir:      getEnv("MAIL_FILE_DIR", "mail"),
+		},
+		Password: PasswordConfig{
+			MinLength:         getEnvInt("PASSWORD_MIN_LENGTH", 6),
+			MaxLength:         getEnvInt("PASSWORD_MAX_LENGTH", 100),
+			RequireUpper:      getEnvBool("PASSWORD_REQUIRE_UPPER", false),
+			RequireLower:      getEnvBool("PASSWORD_REQUIRE_LOWER", false),
+			RequireDigit:      getEnvBool("PASSWORD_REQUIRE_DIGIT", false),
+			RequireSymbol:     getEnvBool("PASSWORD_REQUIRE_SYMBOL", false),
+			DisallowUserInfo:  getEnvBool("PASSWORD_DISALLOW_USER_INFO", false),
+			MaxRepeated:       getEnvInt("PASSWORD_MAX_REPEATED", 0),
+			BreachedDir:       getEnv("BREACHED_PASSWORDS_DIR", ""),
+			Hasher:            getEnv("PASSWORD_HASHER", "argon2id"),
+			Argon2Memory:      getEnvInt("ARGON2_MEMORY", 19456),
+			Argon2Iterations:  getEnvInt("ARGON2_ITERATIONS", 2),
+			Argon2Parallelism: getEnvInt("ARGON2_PARALLELISM", 1),
+			BcryptCost:        getEnvInt("BCRYPT_COST", 10),
+		},
+		Registration: RegistrationConfig{
+			Mode:          getEnv("REGISTRATION_MODE", "open"),
+			InvitationTTL: getEnv("INVITATION_TTL", "168h"),
 		},
+		Export: ExportConfig{
+			Dir:             getEnv("EXPORT_DIR", "exports"),
+			TTL:             getEnv("EXPORT_TTL", "72h"),
+			CleanupInterval: getEnv("EXPORT_CLEANUP_INTERVAL", "1h"),
+			Cooldown:        getEnv("EXPORT_COOLDOWN", "24h"),
+		},
+	}
+
+	switch config.Security.EmailVerification {
+	case "off", "login", "write":
+	default:
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION %q, expected off, login or write", config.Security.EmailVerification)
+	}
+
+	switch config.Registration.Mode {
+	case "open", "invite", "closed":
+	default:
+		return nil, fmt.Errorf("invalid REGISTRATION_MODE %q, expected open, invite or closed", config.Registration.Mode)
+	}
+
+	for name, value := range map[string]int{
+		"PASSWORD_MIN_LENGTH":   config.Password.MinLength,
+		"PASSWORD_MAX_LENGTH":   config.Password.MaxLength,
+		"PASSWORD_MAX_REPEATED": config.Password.MaxRepeated,
+	} {
+		if value < 0 {
+			return nil, fmt.Errorf("invalid %s %d, cannot be negative", name, value)
+		}
+	}
+	if config.Password.MaxLength > 0 && config.Password.MinLength > config.Password.MaxLength {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH %d is greater than PASSWORD_MAX_LENGTH %d", config.Password.MinLength, config.Password.MaxLength)
+	}
+
+	if config.OIDC.Issuer != "" && config.OIDC.ClientID == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+	}
+	if config.OIDC.RedirectURL == "" {
+		config.OIDC.RedirectURL = strings.TrimSuffix(config.Server.PublicURL, "/") + "/auth/oidc/callback"
 	}
 
 	return config, nil
@@ -85,3 +352,57 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvInt gets an integer environment variable with fallback
+func getEnvInt(key string, fallback int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// getEnvBool gets a boolean environment variable with fallback
+func getEnvBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// parseJWTKeys parses a comma-separated list of kid=path[@activeFrom] entries
+func parseJWTKeys(value string) ([]JWTKeyConfig, error) {
+	var keys []JWTKeyConfig
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, rest, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || rest == "" {
+			return nil, fmt.Errorf("invalid JWT_KEYS entry %q, expected kid=path[@activeFrom]", entry)
+		}
+
+		key := JWTKeyConfig{ID: id, Path: rest}
+		if at := strings.LastIndex(rest, "@"); at >= 0 {
+			key.Path, key.ActiveFrom = rest[:at], rest[at+1:]
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// getEnvList gets a comma-separated environment variable with fallback
+func getEnvList(key, fallback string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, fallback), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -23,6 +23,8 @@ func Connect(cfg *config.Config) error {
 	// Connect to PostgreSQL
 	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// Report unique violations as gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 	
 	if err != nil {
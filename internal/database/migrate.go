@@ -1,24 +1,306 @@
 package database
 
 import (
-	"example/go_api_tutorial/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
 	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"example/go_api_tutorial/migrations"
 )
 
-// Migrate runs database migrations
+// migrationLockID is the Postgres advisory lock held while migrating, so that
+// instances starting together don't apply the same migration twice
+const migrationLockID = 7245103318
+
+// migrationFile matches migration file names such as 0001_initial_schema.up.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change with the SQL applying and reverting it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells whether a migration has been applied
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+	Unknown   bool       `json:"unknown,omitempty"` // applied by a newer build, not part of this one
+}
+
+// Migrate brings the schema up to date and seeds the built-in roles and permissions.
+// It refuses to run against a schema changed by a newer build.
 func Migrate() error {
 	log.Println("Running database migrations...")
-	
-	// Auto-migrate the schema
-	err := DB.AutoMigrate(
-		&models.User{},
-		&models.Book{},
-	)
-	
-	if err != nil {
+
+	if err := MigrateUp(); err != nil {
+		return err
+	}
+
+	// Built-in roles and permissions are required for authorization
+	if err := SeedRoles(); err != nil {
 		return err
 	}
-	
+
 	log.Println("Database migrations completed successfully!")
 	return nil
 }
+
+// MigrateUp applies every migration not applied yet, in order, each in its own transaction
+func MigrateUp() error {
+	return withMigrationLock(func(conn *sql.Conn, all []Migration, applied map[int64]time.Time) error {
+		for _, migration := range all {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			log.Printf("Applying migration %04d_%s", migration.Version, migration.Name)
+			err := inTransaction(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)", migration.Version, migration.Name, time.Now())
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown reverts the given number of most recently applied migrations
+func MigrateDown(steps int) error {
+	return withMigrationLock(func(conn *sql.Conn, all []Migration, applied map[int64]time.Time) error {
+		for i := len(all) - 1; i >= 0 && steps > 0; i-- {
+			migration := all[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			log.Printf("Reverting migration %04d_%s", migration.Version, migration.Name)
+			err := inTransaction(conn, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec("DELETE FROM schema_migrations WHERE version = $1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// MigrationStatuses lists every migration of this build and whether it is applied,
+// followed by any applied migration this build doesn't know
+func MigrationStatuses() ([]MigrationStatus, error) {
+	all, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+	conn, release, err := lockMigrations()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	applied, err := appliedMigrations(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(all))
+	known := make(map[int64]bool, len(all))
+	for _, migration := range all {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+		known[migration.Version] = true
+	}
+	for _, version := range sortedVersions(applied) {
+		if !known[version] {
+			appliedAt := applied[version]
+			statuses = append(statuses, MigrationStatus{Version: version, AppliedAt: &appliedAt, Unknown: true})
+		}
+	}
+	return statuses, nil
+}
+
+// LoadMigrations reads the migrations in fsys, sorted by version. Every version
+// needs both an up and a down file.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid version: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two names, %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	all := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		all = append(all, *migration)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// withMigrationLock runs fn holding the migration lock, with the migrations of this
+// build and those already applied. It fails if the database has migrations this build
+// doesn't know, since the schema was then changed by a newer build.
+func withMigrationLock(fn func(conn *sql.Conn, all []Migration, applied map[int64]time.Time) error) error {
+	all, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	conn, release, err := lockMigrations()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	applied, err := appliedMigrations(conn)
+	if err != nil {
+		return err
+	}
+	if err := checkKnown(all, applied); err != nil {
+		return err
+	}
+	return fn(conn, all, applied)
+}
+
+// lockMigrations takes the migration lock on a dedicated connection, as an advisory lock
+// belongs to the connection taking it, and makes sure the tracking table exists. Call
+// release to unlock and return the connection.
+func lockMigrations() (conn *sql.Conn, release func(), err error) {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err = sqlDB.Conn(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Session level lock, so it is also released if the connection is lost
+	if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	release = func() {
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+		conn.Close()
+	}
+
+	_, err = conn.ExecContext(context.Background(), `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return conn, release, nil
+}
+
+// appliedMigrations returns when each applied migration was applied, by version
+func appliedMigrations(conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// checkKnown fails if a migration was applied that isn't among all
+func checkKnown(all []Migration, applied map[int64]time.Time) error {
+	known := make(map[int64]bool, len(all))
+	for _, migration := range all {
+		known[migration.Version] = true
+	}
+	for _, version := range sortedVersions(applied) {
+		if !known[version] {
+			return fmt.Errorf("database has migration %04d, which this build doesn't know; it was migrated by a newer version", version)
+		}
+	}
+	return nil
+}
+
+// inTransaction runs fn in a transaction on conn, committing if it succeeds
+func inTransaction(conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sortedVersions returns the versions in applied in ascending order
+func sortedVersions(applied map[int64]time.Time) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/migrations"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	createTable = regexp.MustCompile(`(?s)CREATE TABLE IF NOT EXISTS "(\w+)" \((.*?)\n\);`)
+	columnDef   = regexp.MustCompile(`(?m)^\s+"(\w+)" `)
+	alterTable  = regexp.MustCompile(`(?s)ALTER TABLE "(\w+)"(.*?);`)
+	addColumn   = regexp.MustCompile(`ADD COLUMN (?:IF NOT EXISTS )?"(\w+)"`)
+)
+
+func TestLoadMigrations(t *testing.T) {
+	all, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 {
+		t.Fatal("no migrations")
+	}
+	for i, migration := range all {
+		if migration.Version != int64(i+1) {
+			t.Errorf("migration %04d_%s is number %d; versions must have no gaps", migration.Version, migration.Name, i+1)
+		}
+	}
+}
+
+// Every model column must be created by some migration, as the models no longer create tables
+func TestMigrationsCreateModelColumns(t *testing.T) {
+	all, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns := make(map[string]map[string]bool)
+	add := func(table, column string) {
+		if columns[table] == nil {
+			columns[table] = make(map[string]bool)
+		}
+		columns[table][column] = true
+	}
+	for _, migration := range all {
+		for _, match := range createTable.FindAllStringSubmatch(migration.Up, -1) {
+			for _, column := range columnDef.FindAllStringSubmatch(match[2], -1) {
+				add(match[1], column[1])
+			}
+		}
+		for _, match := range alterTable.FindAllStringSubmatch(migration.Up, -1) {
+			for _, column := range addColumn.FindAllStringSubmatch(match[2], -1) {
+				add(match[1], column[1])
+			}
+		}
+	}
+
+	cache := &sync.Map{}
+	for _, model := range []interface{}{
+		&models.User{}, &models.Book{}, &models.Loan{}, &models.Hold{}, &models.PasswordHistory{},
+		&models.Session{}, &models.RevokedToken{}, &models.Permission{}, &models.Role{},
+		&models.LoginAttempt{}, &models.RecoveryCode{}, &models.PasswordReset{}, &models.APIKey{},
+		&models.OIDCLogin{}, &models.AuditEvent{}, &models.DataExport{}, &models.Invitation{},
+	} {
+		modelSchema, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range modelSchema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			if !columns[modelSchema.Table][field.DBName] {
+				t.Errorf("no migration creates %s.%s for %s.%s", modelSchema.Table, field.DBName, modelSchema.Name, field.Name)
+			}
+		}
+		for _, relationship := range modelSchema.Relationships.Relations {
+			if join := relationship.JoinTable; join != nil {
+				for _, field := range join.Fields {
+					if field.DBName != "" && !columns[join.Table][field.DBName] {
+						t.Errorf("no migration creates %s.%s", join.Table, field.DBName)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"example/go_api_tutorial/internal/models"
+	"gorm.io/gorm"
+)
+
+// SeedRoles makes sure the built-in permissions and roles exist.
+// Built-in roles get their default permissions when first created; afterwards they
+// only receive newly introduced permissions, so customisations by admins survive
+// restarts. The admin role always holds every permission.
+func SeedRoles() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		added := make(map[string]bool)
+		for _, builtIn := range models.BuiltInPermissions {
+			permission := builtIn
+			result := tx.Where(models.Permission{Name: permission.Name}).FirstOrCreate(&permission)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				added[permission.Name] = true
+			}
+		}
+
+		var permissions []models.Permission
+		if err := tx.Find(&permissions).Error; err != nil {
+			return err
+		}
+
+		for _, builtIn := range models.BuiltInRoles {
+			role := builtIn
+			role.BuiltIn = true
+			result := tx.Where(models.Role{Name: role.Name}).FirstOrCreate(&role)
+			if result.Error != nil {
+				return result.Error
+			}
+			created := result.RowsAffected > 0
+
+			var grant []models.Permission
+			for _, permission := range permissions {
+				switch {
+				case role.Name == models.RoleAdmin:
+					grant = append(grant, permission)
+				case created || added[permission.Name]:
+					if containsString(models.BuiltInRolePermissions[role.Name], permission.Name) {
+						grant = append(grant, permission)
+					}
+				}
+			}
+			if len(grant) == 0 {
+				continue
+			}
+
+			if err := tx.Model(&role).Association("Permissions").Append(grant); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// containsString checks if a string is in the list
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"example/go_api_tutorial/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// AccountHandler handles users closing their account
+type AccountHandler struct {
+	accountService *service.AccountService
+	sessionService *service.SessionService
+}
+
+// NewAccountHandler creates a new account handler
+func NewAccountHandler(accountService *service.AccountService, sessionService *service.SessionService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+		sessionService: sessionService,
+	}
+}
+
+// DeleteAccount handles DELETE /auth/profile (requires authentication)
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		// Password is required unless the account has none and the user signed in within
+		// the last few minutes
+		Password string `json:"password"`
+	}
+
+	// Accounts without a password may send no body at all
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	signedIn, err := signedInAt(c, h.sessionService)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.accountService.DeleteAccount(userID.(uint), req.Password, signedIn); err != nil {
+		switch err.Error() {
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "password is incorrect":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case "sign in again to confirm this change":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case "all borrowed books must be returned first", "cannot remove the last active admin":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler handles users' API keys for machine clients
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// CreateAPIKeyRequest represents the create API key request
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name" binding:"required,max=100"`
+	Permissions []string   `json:"permissions" binding:"required"`
+	ExpiresAt   *time.Time `json:"expires_at"` // defaults to API_KEY_DEFAULT_TTL from now
+}
+
+// APIKeyResponse describes an API key without the key itself
+type APIKeyResponse struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreateAPIKeyResponse carries a new API key, shown only this once
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+// CreateAPIKey handles POST /auth/api-keys (requires authentication)
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	granted, _ := c.Get("scopes")
+	scopes, _ := granted.([]models.Scope)
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, apiKey, err := h.apiKeyService.CreateKey(userID.(uint), scopes, req.Name, req.Permissions, req.ExpiresAt)
+	if err != nil {
+		switch {
+		case err.Error() == "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "permission not granted to you"):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case err.Error() == "name is required",
+			err.Error() == "at least one permission is required",
+			err.Error() == "expiry must be in the future",
+			err.Error() == "expiry is too far in the future",
+			strings.HasPrefix(err.Error(), "unknown permission"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKeyResponse: apiKeyResponse(apiKey), Key: key})
+}
+
+// GetAPIKeys handles GET /auth/api-keys (requires authentication)
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	keys, err := h.apiKeyService.ListKeys(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		response = append(response, apiKeyResponse(&keys[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeAPIKey handles DELETE /auth/api-keys/:id (requires authentication)
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+
+	if err := h.apiKeyService.RevokeKey(userID.(uint), uint(id)); err != nil {
+		if err.Error() == "API key not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// apiKeyResponse builds the response describing an API key
+func apiKeyResponse(key *models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:          key.ID,
+		Name:        key.Name,
+		Prefix:      key.Prefix,
+		Permissions: key.PermissionNames(),
+		ExpiresAt:   key.ExpiresAt,
+		LastUsedAt:  key.LastUsedAt,
+		CreatedAt:   key.CreatedAt,
+	}
+}
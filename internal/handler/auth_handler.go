@@ -1,7 +1,12 @@
 package handler
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"example/go_api_tutorial/internal/models"
 	"example/go_api_tutorial/internal/service"
@@ -11,15 +16,21 @@ import (
 
 // AuthHandler handles authentication requests
 type AuthHandler struct {
-	userService *service.UserService
-	jwtManager  *utils.JWTManager
+	userService              *service.UserService
+	sessionService           *service.SessionService
+	twoFactorService         *service.TwoFactorService
+	emailVerificationService *service.EmailVerificationService
+	invitationService        *service.InvitationService
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(userService *service.UserService, jwtManager *utils.JWTManager) *AuthHandler {
+func NewAuthHandler(userService *service.UserService, sessionService *service.SessionService, twoFactorService *service.TwoFactorService, emailVerificationService *service.EmailVerificationService, invitationService *service.InvitationService) *AuthHandler {
 	return &AuthHandler{
-		userService: userService,
-		jwtManager:  jwtManager,
+		userService:              userService,
+		sessionService:           sessionService,
+		twoFactorService:         twoFactorService,
+		emailVerificationService: emailVerificationService,
+		invitationService:        invitationService,
 	}
 }
 
@@ -27,7 +38,9 @@ func NewAuthHandler(userService *service.UserService, jwtManager *utils.JWTManag
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=50"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
+	// InviteCode is required when registration is invite-only
+	InviteCode string `json:"invite_code"`
 }
 
 // LoginRequest represents the login request
@@ -36,10 +49,49 @@ type LoginRequest struct {
 	Password        string `json:"password" binding:"required"`
 }
 
+// RefreshRequest represents the token refresh request
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 // AuthResponse represents the authentication response
 type AuthResponse struct {
-	User  *models.User `json:"user"`
-	Token string       `json:"token"`
+	User         *models.User `json:"user"`
+	Token        string       `json:"token"`         // short-lived access token
+	RefreshToken string       `json:"refresh_token"` // single-use, rotated on every refresh
+	ExpiresIn    int64        `json:"expires_in"`    // access token lifetime in seconds
+	// TwoFactorSetupRequired is set when the user's role requires 2FA they haven't enabled;
+	// their tokens grant no permissions until they enroll and log in again
+	TwoFactorSetupRequired bool `json:"two_factor_setup_required,omitempty"`
+}
+
+// TwoFactorChallengeResponse is returned by login instead of tokens when the user has 2FA enabled
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"` // exchange with a code at /auth/2fa/challenge
+	ExpiresIn         int64  `json:"expires_in"`      // challenge lifetime in seconds
+}
+
+// SessionResponse represents one of the user's logins
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	StartedAt  time.Time `json:"started_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // the session of the token making the request
+}
+
+// newAuthResponse builds the authentication response from a token pair
+func newAuthResponse(user *models.User, tokens *service.TokenPair) AuthResponse {
+	return AuthResponse{
+		User:         user,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+	}
 }
 
 // Register handles POST /auth/register
@@ -50,23 +102,40 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.RegisterUser(req.Username, req.Email, req.Password)
+	user, err := h.invitationService.Register(req.Username, req.Email, req.Password, req.InviteCode)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		switch err.Error() {
+		case "registration is closed", "an invitation is required to register",
+			"invalid or expired invitation", "invitation is for another email address":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			respondWithPasswordError(c, err)
+		}
+		return
+	}
+
+	// The account exists either way; the user can ask for another link
+	if err := h.emailVerificationService.SendVerification(user); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	// Logging in has to wait until the email address is confirmed
+	if h.userService.EmailVerificationMode() == service.EmailVerificationLogin {
+		c.JSON(http.StatusCreated, gin.H{
+			"user":    user,
+			"message": "Account created, check your email to verify your address before logging in",
+		})
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.jwtManager.GenerateToken(user)
+	// Start a session with access and refresh tokens
+	tokens, err := h.sessionService.StartSession(user, false, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, AuthResponse{
-		User:  user,
-		Token: token,
-	})
+	c.JSON(http.StatusCreated, h.authResponse(user, tokens))
 }
 
 // Login handles POST /auth/login
@@ -79,27 +148,101 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	user, err := h.userService.LoginUser(req.UsernameOrEmail, req.Password)
 	if err != nil {
+		var throttled *service.ThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if err.Error() == "email address has not been verified" || err.Error() == "account is suspended" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.jwtManager.GenerateToken(user)
+	// The password is not enough, the user has to supply a code next
+	if user.TwoFactorEnabled {
+		challenge, err := h.twoFactorService.StartChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor login"})
+			return
+		}
+
+		c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int64(h.twoFactorService.ChallengeTTL().Seconds()),
+		})
+		return
+	}
+
+	// Start a session with access and refresh tokens
+	tokens, err := h.sessionService.StartSession(user, false, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, AuthResponse{
-		User:  user,
-		Token: token,
-	})
+	c.JSON(http.StatusOK, h.authResponse(user, tokens))
 }
 
 // RefreshToken handles POST /auth/refresh
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, tokens, err := h.sessionService.Refresh(req.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		if err.Error() == "invalid refresh token" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.authResponse(user, tokens))
+}
+
+// authResponse builds the authentication response, flagging users who still have to enroll in 2FA
+func (h *AuthHandler) authResponse(user *models.User, tokens *service.TokenPair) AuthResponse {
+	response := newAuthResponse(user, tokens)
+	response.TwoFactorSetupRequired = h.twoFactorService.IsRequired(user.Role) && !user.TwoFactorEnabled
+	return response
+}
+
+// VerifyEmail handles GET /auth/verify-email?token=
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification token required"})
+		return
+	}
+
+	if err := h.emailVerificationService.Verify(token); err != nil {
+		switch err.Error() {
+		case "invalid or expired verification link":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case "email address is already verified":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address verified successfully"})
+}
+
+// ResendVerification handles POST /auth/verify-email/resend
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
 	var req struct {
-		Token string `json:"token" binding:"required"`
+		Email string `json:"email" binding:"required,email"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -107,13 +250,12 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	newToken, err := h.jwtManager.RefreshToken(req.Token)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-		return
+	if err := h.emailVerificationService.Resend(req.Email); err != nil {
+		log.Printf("Failed to resend verification email: %v", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": newToken})
+	// Same answer whether or not the address has an unverified account
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the address belongs to an unverified account, a new link is on its way"})
 }
 
 // GetProfile handles GET /auth/profile (requires authentication)
@@ -133,6 +275,68 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// UpdateProfileRequest represents the update profile request; omitted fields are left unchanged
+type UpdateProfileRequest struct {
+	Username *string `json:"username" binding:"omitempty,min=3,max=50"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+	// CurrentPassword is required to change the email address, unless the account has no
+	// password and the user signed in within the last few minutes
+	CurrentPassword string `json:"current_password"`
+}
+
+// UpdateProfile handles PATCH /auth/profile (requires authentication)
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var signedIn time.Time
+	if req.Email != nil {
+		var err error
+		if signedIn, err = signedInAt(c, h.sessionService); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user, previousEmail, err := h.userService.UpdateProfile(userID.(uint), req.Username, req.Email, req.CurrentPassword, signedIn)
+	if err != nil {
+		switch err.Error() {
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "username is required", "email is required", "password is incorrect":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case "sign in again to confirm this change":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case "username already exists", "email already exists", "username or email already exists":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if previousEmail != "" {
+		// The address is changed either way; the user can ask for another link
+		if err := h.emailVerificationService.SendVerification(user); err != nil {
+			log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+		}
+		if err := h.emailVerificationService.NotifyEmailChanged(user, previousEmail); err != nil {
+			log.Printf("Failed to notify previous email address of user %d: %v", user.ID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
 // ChangePassword handles POST /auth/change-password (requires authentication)
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -143,7 +347,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 
 	var req struct {
 		CurrentPassword string `json:"current_password" binding:"required"`
-		NewPassword     string `json:"new_password" binding:"required,min=6"`
+		NewPassword     string `json:"new_password" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -152,9 +356,101 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	}
 
 	if err := h.userService.ChangePassword(userID.(uint), req.CurrentPassword, req.NewPassword); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondWithPasswordError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully, please log in again"})
+}
+
+// Logout handles POST /auth/logout (requires authentication)
+func (h *AuthHandler) Logout(c *gin.Context) {
+	claims, exists := c.Get("claims")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.sessionService.Logout(claims.(*utils.JWTClaims)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// GetSessions handles GET /auth/sessions (requires authentication)
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	claims, exists := c.Get("claims")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
+	current := claims.(*utils.JWTClaims)
+
+	sessions, err := h.sessionService.ListSessions(current.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			ID:         session.FamilyID,
+			Device:     session.Device,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			StartedAt:  session.StartedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.FamilyID == current.SessionID,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+// RevokeSession handles DELETE /auth/sessions/:id (requires authentication)
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(userID.(uint), c.Param("id")); err != nil {
+		if err.Error() == "session not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// respondWithPasswordError answers 400 with the error, listing each broken rule
+// when a password didn't meet the policy
+func respondWithPasswordError(c *gin.Context, err error) {
+	var policyErr *utils.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      "Password does not meet the requirements",
+			"violations": policyErr.Violations,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// signedInAt returns when the login the request's access token belongs to started; the
+// zero time for requests without one, such as those made with an API key
+func signedInAt(c *gin.Context, sessionService *service.SessionService) (time.Time, error) {
+	claims, exists := c.Get("claims")
+	if !exists {
+		return time.Time{}, nil
+	}
+	return sessionService.SignedInAt(claims.(*utils.JWTClaims))
 }
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// DataExportHandler handles users downloading the personal data stored about them
+type DataExportHandler struct {
+	dataExportService *service.DataExportService
+}
+
+// NewDataExportHandler creates a new data export handler
+func NewDataExportHandler(dataExportService *service.DataExportService) *DataExportHandler {
+	return &DataExportHandler{
+		dataExportService: dataExportService,
+	}
+}
+
+// RequestExport handles POST /auth/profile/export (requires authentication)
+func (h *DataExportHandler) RequestExport(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	export, err := h.dataExportService.RequestExport(userID.(uint))
+	if err != nil {
+		var throttled *service.ThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, export)
+}
+
+// DownloadExport handles GET /auth/profile/export/:id (requires authentication).
+// It answers 202 with the export's status until the archive is ready.
+func (h *DataExportHandler) DownloadExport(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID"})
+		return
+	}
+
+	export, err := h.dataExportService.GetExport(uint(id), userID.(uint))
+	if err != nil {
+		switch err.Error() {
+		case "export not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "export has expired":
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	switch export.Status {
+	case models.DataExportPending:
+		c.JSON(http.StatusAccepted, export)
+	case models.DataExportFailed:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Export failed, please request a new one"})
+	default:
+		c.FileAttachment(export.FilePath, fmt.Sprintf("data-export-%d.zip", export.ID))
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// HoldHandler handles HTTP requests for book holds
+type HoldHandler struct {
+	holdService *service.HoldService
+	roleService *service.RoleService
+}
+
+// NewHoldHandler creates a new hold handler
+func NewHoldHandler(holdService *service.HoldService, roleService *service.RoleService) *HoldHandler {
+	return &HoldHandler{
+		holdService: holdService,
+		roleService: roleService,
+	}
+}
+
+// PlaceHold handles POST /books/:id/holds (requires authentication)
+func (h *HoldHandler) PlaceHold(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	hold, err := h.holdService.PlaceHold(userID.(uint), uint(id))
+	if err != nil {
+		switch err.Error() {
+		case "book not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "copies are available, check out the book instead", "hold already exists for this book":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, hold)
+}
+
+// GetMyHolds handles GET /holds (requires authentication)
+func (h *HoldHandler) GetMyHolds(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	holds, err := h.holdService.GetUserHolds(userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, holds)
+}
+
+// CancelHold handles DELETE /holds/:id (requires authentication)
+func (h *HoldHandler) CancelHold(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold ID"})
+		return
+	}
+
+	canManage, err := hasPermission(c, h.roleService, models.PermissionLoanManage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+
+	if err := h.holdService.CancelHold(uint(id), userID.(uint), canManage); err != nil {
+		switch err.Error() {
+		case "hold not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "hold is no longer active":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hold cancelled successfully"})
+}
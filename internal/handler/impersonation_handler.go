@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/service"
+	"example/go_api_tutorial/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ImpersonationHandler handles support staff acting as other users
+type ImpersonationHandler struct {
+	impersonationService *service.ImpersonationService
+}
+
+// NewImpersonationHandler creates a new impersonation handler
+func NewImpersonationHandler(impersonationService *service.ImpersonationService) *ImpersonationHandler {
+	return &ImpersonationHandler{
+		impersonationService: impersonationService,
+	}
+}
+
+// ImpersonationResponse carries a token for acting as another user
+type ImpersonationResponse struct {
+	User      *models.User `json:"user"`       // the impersonated user
+	Token     string       `json:"token"`      // access token, not refreshable
+	ExpiresIn int64        `json:"expires_in"` // token lifetime in seconds
+}
+
+// Impersonate handles POST /users/:id/impersonate (admin only)
+func (h *ImpersonationHandler) Impersonate(c *gin.Context) {
+	claims, exists := c.Get("claims")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	token, user, err := h.impersonationService.Impersonate(claims.(*utils.JWTClaims), uint(id), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch err.Error() {
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "cannot impersonate an admin", "cannot impersonate yourself", "cannot impersonate while impersonating":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to impersonate user"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ImpersonationResponse{
+		User:      user,
+		Token:     token,
+		ExpiresIn: int64(h.impersonationService.TokenTTL().Seconds()),
+	})
+}
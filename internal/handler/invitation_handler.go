@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// InvitationHandler handles registration invitations (admin only)
+type InvitationHandler struct {
+	invitationService *service.InvitationService
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler(invitationService *service.InvitationService) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+	}
+}
+
+// CreateInvitationRequest represents the create invitation request
+type CreateInvitationRequest struct {
+	Role      models.UserRole `json:"role"`                               // defaults to user
+	Email     string          `json:"email" binding:"omitempty,email"`    // only this address may register
+	MaxUses   int             `json:"max_uses" binding:"omitempty,min=1"` // defaults to 1
+	ExpiresAt *time.Time      `json:"expires_at"`                         // defaults to INVITATION_TTL from now
+}
+
+// CreateInvitation handles POST /invitations (admin only)
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code, invitation, err := h.invitationService.CreateInvitation(userID.(uint), req.Role, req.Email, req.MaxUses, req.ExpiresAt)
+	if err != nil {
+		switch {
+		case err.Error() == "invalid role",
+			err.Error() == "max uses must be positive",
+			err.Error() == "expiry must be in the future":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "inviting to a role"):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":       code, // shown only this once
+		"invitation": invitation,
+	})
+}
+
+// GetInvitations handles GET /invitations (admin only)
+func (h *InvitationHandler) GetInvitations(c *gin.Context) {
+	invitations, err := h.invitationService.ListInvitations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// RevokeInvitation handles DELETE /invitations/:id (admin only)
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	if err := h.invitationService.RevokeInvitation(uint(id)); err != nil {
+		if err.Error() == "invitation not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+
+	"example/go_api_tutorial/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public keys access tokens are signed with
+type JWKSHandler struct {
+	jwtManager *utils.JWTManager
+}
+
+// NewJWKSHandler creates a new JWKS handler
+func NewJWKSHandler(jwtManager *utils.JWTManager) *JWKSHandler {
+	return &JWKSHandler{
+		jwtManager: jwtManager,
+	}
+}
+
+// GetJWKS handles GET /.well-known/jwks.json
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	// Verifiers may cache the set briefly; upcoming keys are published ahead of rotation
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtManager.JWKS())
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// LoanHandler handles HTTP requests for book loans
+type LoanHandler struct {
+	loanService *service.LoanService
+	roleService *service.RoleService
+}
+
+// NewLoanHandler creates a new loan handler
+func NewLoanHandler(loanService *service.LoanService, roleService *service.RoleService) *LoanHandler {
+	return &LoanHandler{
+		loanService: loanService,
+		roleService: roleService,
+	}
+}
+
+// Checkout handles POST /books/:id/checkout (requires authentication)
+func (h *LoanHandler) Checkout(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+
+	loan, err := h.loanService.CheckoutBook(userID.(uint), uint(id))
+	if err != nil {
+		switch err.Error() {
+		case "book not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "no copies available":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, loan)
+}
+
+// ReturnLoan handles POST /loans/:id/return (requires authentication)
+func (h *LoanHandler) ReturnLoan(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	canManage, err := hasPermission(c, h.roleService, models.PermissionLoanManage)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+		return
+	}
+
+	loan, err := h.loanService.ReturnLoan(uint(id), userID.(uint), canManage)
+	if err != nil {
+		switch err.Error() {
+		case "loan not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "loan already returned":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, loan)
+}
+
+// GetMyLoans handles GET /loans (requires authentication)
+func (h *LoanHandler) GetMyLoans(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	activeOnly := c.Query("active") == "true"
+
+	loans, err := h.loanService.GetUserLoans(userID.(uint), activeOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, loans)
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"example/go_api_tutorial/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookie binds a sign-in started at the identity provider to the browser that started it
+const oidcStateCookie = "oidc_state"
+
+// OIDCHandler handles sign-in through an external OpenID Connect provider
+type OIDCHandler struct {
+	oidcService      *service.OIDCService
+	sessionService   *service.SessionService
+	twoFactorService *service.TwoFactorService
+	secureCookies    bool
+}
+
+// NewOIDCHandler creates a new OIDC handler. secureCookies should be set when the API is served over HTTPS.
+func NewOIDCHandler(oidcService *service.OIDCService, sessionService *service.SessionService, twoFactorService *service.TwoFactorService, secureCookies bool) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService:      oidcService,
+		sessionService:   sessionService,
+		twoFactorService: twoFactorService,
+		secureCookies:    secureCookies,
+	}
+}
+
+// Login handles GET /auth/oidc/login by redirecting to the identity provider
+func (h *OIDCHandler) Login(c *gin.Context) {
+	authURL, state, err := h.oidcService.StartLogin(c.Request.Context())
+	if err != nil {
+		log.Printf("Failed to start OIDC sign-in: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	// Lax, so the cookie comes along on the provider's redirect back
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state, int(h.oidcService.LoginTTL().Seconds()), "/auth/oidc", "", h.secureCookies, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback handles GET /auth/oidc/callback, where the identity provider sends the user back
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	browserState, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", h.secureCookies, true)
+
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Sign-in was not completed", "reason": providerError})
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code required"})
+		return
+	}
+
+	user, mfa, err := h.oidcService.CompleteLogin(c.Request.Context(), c.Query("state"), browserState, code)
+	if err != nil {
+		switch {
+		case err.Error() == "invalid or expired sign-in":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case err.Error() == "identity provider sign-in failed",
+			err.Error() == "identity provider did not confirm an email address":
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case err.Error() == "account is suspended",
+			err.Error() == "no account found for this identity":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case err.Error() == "account is linked to another identity":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "could not find"):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign in"})
+		}
+		return
+	}
+
+	// Users who turned on two-factor login here still need a code, unless the provider checked one
+	if user.TwoFactorEnabled && !mfa {
+		challenge, err := h.twoFactorService.StartChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor login"})
+			return
+		}
+
+		c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int64(h.twoFactorService.ChallengeTTL().Seconds()),
+		})
+		return
+	}
+
+	// Start a session with access and refresh tokens
+	tokens, err := h.sessionService.StartSession(user, mfa, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	response := newAuthResponse(user, tokens)
+	response.TwoFactorSetupRequired = h.twoFactorService.IsRequired(user.Role) && !user.TwoFactorEnabled && !mfa
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"example/go_api_tutorial/internal/service"
+	"example/go_api_tutorial/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordResetHandler handles forgotten password requests
+type PasswordResetHandler struct {
+	passwordResetService *service.PasswordResetService
+}
+
+// NewPasswordResetHandler creates a new password reset handler
+func NewPasswordResetHandler(passwordResetService *service.PasswordResetService) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		passwordResetService: passwordResetService,
+	}
+}
+
+// ResetPasswordRequest represents the password reset request
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ForgotPassword handles POST /auth/forgot-password
+func (h *PasswordResetHandler) ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Send in the background so the response time doesn't reveal whether the address has an account
+	if !h.passwordResetService.QueueReset(req.Email) {
+		log.Printf("Password reset queue is full, dropped a request")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the address belongs to an account, a reset link is on its way"})
+}
+
+// ResetPassword handles POST /auth/reset-password
+func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.passwordResetService.ResetPassword(req.Token, req.NewPassword); err != nil {
+		var policyErr *utils.PasswordPolicyError
+		switch {
+		case errors.As(err, &policyErr),
+			err.Error() == "invalid or expired reset token",
+			err.Error() == "new password must be different from the current password",
+			strings.HasPrefix(err.Error(), "new password must not match any of your last"):
+			respondWithPasswordError(c, err)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully, please log in with your new password"})
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler handles role and permission management requests
+type RoleHandler struct {
+	roleService *service.RoleService
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(roleService *service.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+// RoleRequest represents the create role request
+type RoleRequest struct {
+	Name        models.UserRole `json:"name" binding:"required"`
+	Description string          `json:"description" binding:"max=255"`
+	Permissions []string        `json:"permissions"`
+}
+
+// RolePermissionsRequest represents the set role permissions request
+type RolePermissionsRequest struct {
+	Permissions []string `json:"permissions" binding:"required"`
+}
+
+// GetRoles handles GET /roles
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	roles, err := h.roleService.GetAllRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// GetPermissions handles GET /roles/permissions
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	permissions, err := h.roleService.GetAllPermissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// CreateRole handles POST /roles
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.roleService.CreateRole(req.Name, req.Description, req.Permissions)
+	if err != nil {
+		if err.Error() == "role already exists" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// SetRolePermissions handles PUT /roles/:name/permissions
+func (h *RoleHandler) SetRolePermissions(c *gin.Context) {
+	var req RolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := h.roleService.SetRolePermissions(models.UserRole(c.Param("name")), req.Permissions)
+	if err != nil {
+		if err.Error() == "role not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole handles DELETE /roles/:name
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	if err := h.roleService.DeleteRole(models.UserRole(c.Param("name"))); err != nil {
+		switch {
+		case err.Error() == "role not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "built-in"), err.Error() == "role is still assigned to users":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+// hasPermission checks if the authenticated user's role grants a permission
+func hasPermission(c *gin.Context, roleService *service.RoleService, permission string) (bool, error) {
+	role, exists := c.Get("role")
+	if !exists {
+		return false, nil
+	}
+	userRole, _ := role.(models.UserRole)
+
+	// Requests made with an API key are limited to the key's permissions
+	if value, ok := c.Get("permissions"); ok {
+		keyPermissions, _ := value.([]string)
+		granted := false
+		for _, p := range keyPermissions {
+			if p == permission {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			return false, nil
+		}
+	}
+
+	return roleService.HasPermission(userRole, permission)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"example/go_api_tutorial/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler handles TOTP enrollment and two-factor login
+type TwoFactorHandler struct {
+	twoFactorService *service.TwoFactorService
+	sessionService   *service.SessionService
+}
+
+// NewTwoFactorHandler creates a new two-factor handler
+func NewTwoFactorHandler(twoFactorService *service.TwoFactorService, sessionService *service.SessionService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+		sessionService:   sessionService,
+	}
+}
+
+// TwoFactorSetupResponse carries a new TOTP secret to add to an authenticator app
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"` // render as a QR code for the app to scan
+}
+
+// TwoFactorChallengeRequest completes a login started with a password
+type TwoFactorChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+// Setup handles POST /auth/2fa/setup (requires authentication)
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	secret, uri, err := h.twoFactorService.Setup(userID.(uint))
+	if err != nil {
+		switch err.Error() {
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "two-factor authentication is already enabled":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorSetupResponse{Secret: secret, OTPAuthURI: uri})
+}
+
+// Verify handles POST /auth/2fa/verify (requires authentication)
+func (h *TwoFactorHandler) Verify(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recoveryCodes, err := h.twoFactorService.Enable(userID.(uint), req.Code)
+	if err != nil {
+		switch err.Error() {
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "two-factor authentication is already enabled":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case "two-factor setup has not been started", "invalid two-factor code":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled, please log in again",
+		"recovery_codes": recoveryCodes,
+	})
+}
+
+// Challenge handles POST /auth/2fa/challenge
+func (h *TwoFactorHandler) Challenge(c *gin.Context) {
+	var req TwoFactorChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.twoFactorService.CompleteChallenge(req.ChallengeToken, req.Code)
+	if err != nil {
+		var throttled *service.ThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+
+		switch err.Error() {
+		case "invalid or expired challenge", "invalid two-factor code":
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// Start a session with access and refresh tokens
+	tokens, err := h.sessionService.StartSession(user, true, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newAuthResponse(user, tokens))
+}
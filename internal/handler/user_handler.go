@@ -3,33 +3,89 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
 	"example/go_api_tutorial/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
 // UserHandler handles user management requests (admin only)
 type UserHandler struct {
-	userService *service.UserService
+	userService    *service.UserService
+	sessionService *service.SessionService
+	apiKeyService  *service.APIKeyService
+	accountService *service.AccountService
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(userService *service.UserService) *UserHandler {
+func NewUserHandler(userService *service.UserService, sessionService *service.SessionService, apiKeyService *service.APIKeyService, accountService *service.AccountService) *UserHandler {
 	return &UserHandler{
-		userService: userService,
+		userService:    userService,
+		sessionService: sessionService,
+		apiKeyService:  apiKeyService,
+		accountService: accountService,
 	}
 }
 
-// GetAllUsers handles GET /users (admin only)
-func (h *UserHandler) GetAllUsers(c *gin.Context) {
-	users, err := h.userService.GetAllUsers()
+// ListUsers handles GET /users (admin only).
+// Query parameters: page, page_size, sort (id, username, email, created_at), order (asc, desc),
+// role, status (active, suspended), q (username or email substring), created_after, created_before (RFC 3339).
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	filter := interfaces.UserFilter{
+		Role:   models.UserRole(c.Query("role")),
+		Status: c.Query("status"),
+		Search: c.Query("q"),
+		SortBy: c.Query("sort"),
+	}
+
+	switch c.Query("order") {
+	case "", "asc":
+	case "desc":
+		filter.Descending = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order, expected asc or desc"})
+		return
+	}
+
+	for param, target := range map[string]**time.Time{
+		"created_after":  &filter.CreatedAfter,
+		"created_before": &filter.CreatedBefore,
+	} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected an RFC 3339 time"})
+			return
+		}
+		*target = &t
+	}
+
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	result, err := h.userService.ListUsers(filter, page, pageSize)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch err.Error() {
+		case "invalid status", "invalid sort field":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, gin.H{
+		"users":       result.Users,
+		"total":       result.Total,
+		"page":        result.Page,
+		"page_size":   result.PageSize,
+		"total_pages": (result.Total + int64(result.PageSize) - 1) / int64(result.PageSize),
+	})
 }
 
 // GetUserByID handles GET /users/:id (admin only)
@@ -41,7 +97,7 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUserByID(uint(id))
+	user, err := h.userService.GetUserWithLockout(uint(id))
 	if err != nil {
 		if err.Error() == "user not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
@@ -77,9 +133,149 @@ func (h *UserHandler) UpdateUserRole(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		if err.Error() == "cannot remove the last active admin" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
 }
+
+// RevokeUserSessions handles DELETE /users/:id/sessions (admin only).
+// It also revokes the user's API keys, so no access remains.
+func (h *UserHandler) RevokeUserSessions(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if _, err := h.userService.GetUserByID(uint(id)); err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.sessionService.RevokeAllForUser(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.apiKeyService.RevokeAllForUser(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions and API keys revoked successfully"})
+}
+
+// UnlockUser handles POST /users/:id/unlock (admin only)
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.userService.UnlockUser(uint(id)); err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// SuspendUser handles POST /users/:id/suspend (admin only)
+func (h *UserHandler) SuspendUser(c *gin.Context) {
+	actorID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.userService.SuspendUser(actorID.(uint), uint(id)); err != nil {
+		switch err.Error() {
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "cannot suspend your own account", "user is already suspended", "cannot remove the last active admin":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User suspended successfully"})
+}
+
+// ReactivateUser handles POST /users/:id/reactivate (admin only)
+func (h *UserHandler) ReactivateUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.userService.ReactivateUser(uint(id)); err != nil {
+		switch err.Error() {
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "user is not suspended":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User reactivated successfully"})
+}
+
+// DeleteUser handles DELETE /users/:id (admin only)
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	actorID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.accountService.DeleteUser(actorID.(uint), uint(id)); err != nil {
+		switch err.Error() {
+		case "user not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "cannot delete your own account", "all borrowed books must be returned first", "cannot remove the last active admin":
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
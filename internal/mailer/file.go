@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileMailer writes each email to its own .eml file in a directory
+type fileMailer struct {
+	from string
+	dir  string
+
+	mu  sync.Mutex
+	seq int
+}
+
+// NewFileMailer creates a mailer that stores emails as .eml files in dir, creating it if needed
+func NewFileMailer(from, dir string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileMailer{from: from, dir: dir}, nil
+}
+
+// Send writes the email to a new file
+func (m *fileMailer) Send(msg Message) error {
+	if err := validateHeaders(m.from, msg.To, msg.Subject); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().UTC().Format("20060102T150405.000000000"), m.seq)
+	m.mu.Unlock()
+
+	return os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg), 0o600)
+}
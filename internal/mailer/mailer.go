@@ -0,0 +1,70 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails. Deployments use SMTP; the log and file mailers let
+// development and tests read what would have been sent.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// format renders a message as an RFC 5322 email
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// validateHeaders rejects header values that could inject extra headers
+func validateHeaders(values ...string) error {
+	for _, value := range values {
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid email header value %q", value)
+		}
+	}
+	return nil
+}
+
+// linkToken matches the token of a password reset or verification link
+var linkToken = regexp.MustCompile(`([?&]token=)[^&\s]+`)
+
+// logMailer writes emails to the application log instead of sending them.
+// For development only: it doesn't deliver anything.
+type logMailer struct {
+	from string
+}
+
+// NewLogMailer creates a mailer that logs every email. Link tokens are redacted, as
+// whoever reads the log could otherwise use them to take over accounts.
+func NewLogMailer(from string) Mailer {
+	return &logMailer{from: from}
+}
+
+// Send logs the email with its link tokens redacted
+func (m *logMailer) Send(msg Message) error {
+	if err := validateHeaders(msg.To, msg.Subject); err != nil {
+		return err
+	}
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, linkToken.ReplaceAllString(msg.Body, "${1}REDACTED"))
+	return nil
+}
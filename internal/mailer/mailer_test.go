@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerRedactsLinkTokens(t *testing.T) {
+	var out bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&out)
+
+	err := NewLogMailer("library@example.com").Send(Message{
+		To:      "alice@example.com",
+		Subject: "Reset your password",
+		Body:    "Open http://localhost:3000/reset-password?token=s3cret%2Btoken to choose a new one.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "s3cret") {
+		t.Errorf("token written to the log: %s", out.String())
+	}
+	if !strings.Contains(out.String(), "reset-password?token=REDACTED to choose") {
+		t.Errorf("link missing from the log: %s", out.String())
+	}
+}
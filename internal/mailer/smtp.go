@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// smtpMailer sends emails through an SMTP server
+type smtpMailer struct {
+	from string
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer creates a mailer sending through host:port. Credentials are optional;
+// when given they are only sent over TLS (STARTTLS), or to a server on localhost.
+func NewSMTPMailer(from, host, port, username, password string) Mailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpMailer{
+		from: from,
+		addr: net.JoinHostPort(host, port),
+		auth: auth,
+	}
+}
+
+// Send delivers the email to the SMTP server
+func (m *smtpMailer) Send(msg Message) error {
+	if err := validateHeaders(m.from, msg.To, msg.Subject); err != nil {
+		return err
+	}
+
+	// The envelope takes bare addresses, the From header may carry a display name
+	sender, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, sender.Address, []string{msg.To}, format(m.from, msg))
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"example/go_api_tutorial/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Auditor records audit events
+type Auditor interface {
+	Record(event *models.AuditEvent)
+}
+
+// AuditImpersonation records every request made with an impersonation token,
+// with the response status, once it has been handled
+func AuditImpersonation(auditor Auditor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		value, ok := c.Get("impersonator_id")
+		if !ok {
+			return
+		}
+		actorID, _ := value.(uint)
+		userID, _ := c.Get("user_id")
+		targetID, _ := userID.(uint)
+
+		auditor.Record(&models.AuditEvent{
+			Action:    models.AuditImpersonationRequest,
+			UserID:    targetID,
+			ActorID:   &actorID,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		})
+	}
+}
@@ -9,9 +9,27 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware creates JWT authentication middleware
-func AuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
+// ClaimsVerifier checks validated token claims against current server-side state,
+// e.g. whether the user still exists or the token has been revoked. A verifier may
+// also update the claims, such as replacing a stale role with the current one.
+type ClaimsVerifier interface {
+	VerifyClaims(claims *utils.JWTClaims) error
+}
+
+// APIKeyAuthenticator resolves an API key to the user and permissions it acts with
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(key string) (*models.APIKeyGrant, error)
+}
+
+// AuthMiddleware creates authentication middleware accepting a JWT bearer token or,
+// when apiKeys is set, an API key in the X-API-Key header or as "Authorization: ApiKey <key>"
+func AuthMiddleware(jwtManager *utils.JWTManager, apiKeys APIKeyAuthenticator, verifiers ...ClaimsVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key, ok := apiKeyFromRequest(c); ok {
+			authenticateAPIKey(c, apiKeys, key)
+			return
+		}
+
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -43,17 +61,65 @@ func AuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		if err := verifyClaims(claims, verifiers); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.Abort()
+			return
+		}
+
 		// Set user information in context
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
+		c.Set("scopes", claims.Scopes)
 		c.Set("claims", claims)
+		if claims.Act != nil {
+			c.Set("impersonator_id", claims.Act.UserID)
+		}
 
 		c.Next()
 	}
 }
 
+// apiKeyFromRequest returns the API key the request carries, if any
+func apiKeyFromRequest(c *gin.Context) (string, bool) {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key, true
+	}
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "ApiKey ") {
+		return strings.TrimPrefix(authHeader, "ApiKey "), true
+	}
+	return "", false
+}
+
+// authenticateAPIKey authenticates the request with an API key. The key's own permissions
+// are kept in the context for RequirePermission, since scopes alone are coarser.
+func authenticateAPIKey(c *gin.Context, apiKeys APIKeyAuthenticator, key string) {
+	if apiKeys == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "API keys are not accepted here"})
+		c.Abort()
+		return
+	}
+
+	grant, err := apiKeys.AuthenticateAPIKey(key)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", grant.User.ID)
+	c.Set("username", grant.User.Username)
+	c.Set("email", grant.User.Email)
+	c.Set("role", grant.User.Role)
+	c.Set("scopes", grant.Scopes)
+	c.Set("permissions", grant.Permissions)
+	c.Set("api_key_id", grant.Key.ID)
+
+	c.Next()
+}
+
 // AdminMiddleware ensures only admin users can access the endpoint
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -75,8 +141,113 @@ func AdminMiddleware() gin.HandlerFunc {
 	}
 }
 
+// RequireScopes ensures the token carries every one of the given scopes
+func RequireScopes(scopes ...models.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("scopes")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token scopes not found"})
+			c.Abort()
+			return
+		}
+
+		granted, _ := value.([]models.Scope)
+		for _, scope := range scopes {
+			if !models.HasScope(granted, scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient scope", "required_scope": scope})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// PermissionChecker looks up whether a role grants a permission
+type PermissionChecker interface {
+	HasPermission(role models.UserRole, permission string) (bool, error)
+}
+
+// RequirePermission ensures the user's role grants the permission and the token
+// carries the scope covering it
+func RequirePermission(checker PermissionChecker, permission string) gin.HandlerFunc {
+	scope, _ := models.ScopeForPermission(permission)
+
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User role not found"})
+			c.Abort()
+			return
+		}
+
+		userRole, _ := role.(models.UserRole)
+		allowed, err := checker.HasPermission(userRole, permission)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied", "required_permission": permission})
+			c.Abort()
+			return
+		}
+
+		// API keys only hold some of their owner's permissions
+		if value, ok := c.Get("permissions"); ok {
+			keyPermissions, _ := value.([]string)
+			if !containsPermission(keyPermissions, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Permission not granted to this API key", "required_permission": permission})
+				c.Abort()
+				return
+			}
+		}
+
+		granted, _ := c.Get("scopes")
+		scopes, _ := granted.([]models.Scope)
+		if !models.HasScope(scopes, scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient scope", "required_scope": scope})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession rejects requests authenticated with an API key or an impersonation
+// token, for account management that needs a user's own login
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_key_id"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint can't be used with an API key"})
+			c.Abort()
+			return
+		}
+		if _, ok := c.Get("impersonator_id"); ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint can't be used while impersonating"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// containsPermission checks if a permission is in the list
+func containsPermission(permissions []string, permission string) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
 // OptionalAuthMiddleware provides optional authentication (doesn't abort if no token)
-func OptionalAuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
+func OptionalAuthMiddleware(jwtManager *utils.JWTManager, verifiers ...ClaimsVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -86,11 +257,12 @@ func OptionalAuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
 
 		if strings.HasPrefix(authHeader, "Bearer ") {
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			if claims, err := jwtManager.ValidateToken(tokenString); err == nil {
+			if claims, err := jwtManager.ValidateToken(tokenString); err == nil && verifyClaims(claims, verifiers) == nil {
 				c.Set("user_id", claims.UserID)
 				c.Set("username", claims.Username)
 				c.Set("email", claims.Email)
 				c.Set("role", claims.Role)
+				c.Set("scopes", claims.Scopes)
 				c.Set("claims", claims)
 			}
 		}
@@ -98,3 +270,13 @@ func OptionalAuthMiddleware(jwtManager *utils.JWTManager) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// verifyClaims runs the claims through every verifier, stopping at the first failure
+func verifyClaims(claims *utils.JWTClaims, verifiers []ClaimsVerifier) error {
+	for _, verifier := range verifiers {
+		if err := verifier.VerifyClaims(claims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
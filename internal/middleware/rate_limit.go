@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimiter counts a client's requests and reports how long it must wait
+// when it is over its limit
+type RateLimiter interface {
+	Allow(client string) (retryAfter time.Duration, err error)
+}
+
+// RateLimit throttles requests per client IP, answering 429 with a Retry-After header
+func RateLimit(limiter RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		retryAfter, err := limiter.Allow(c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check rate limit"})
+			c.Abort()
+			return
+		}
+
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, try again later"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds for the Retry-After header
+func retryAfterSeconds(wait time.Duration) int {
+	return int(math.Ceil(wait.Seconds()))
+}
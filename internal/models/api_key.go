@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// APIKey is a long-lived credential for a machine client acting as its owner.
+// A key only holds the permissions it was created with, which must be a subset
+// of the owner's role.
+type APIKey struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	UserID      uint         `json:"user_id" gorm:"not null;index"`
+	Name        string       `json:"name" gorm:"not null;size:100"`
+	Prefix      string       `json:"prefix" gorm:"not null;size:16"`        // start of the key, shown to tell keys apart
+	KeyHash     string       `json:"-" gorm:"uniqueIndex;not null;size:64"` // SHA-256 of the key
+	Permissions []Permission `json:"permissions" gorm:"many2many:api_key_permissions"`
+	ExpiresAt   time.Time    `json:"expires_at" gorm:"not null"`
+	LastUsedAt  *time.Time   `json:"last_used_at"`
+	RevokedAt   *time.Time   `json:"revoked_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// IsUsable checks if the key can still authenticate requests
+func (k *APIKey) IsUsable(now time.Time) bool {
+	return k.RevokedAt == nil && now.Before(k.ExpiresAt)
+}
+
+// PermissionNames returns the names of the key's permissions
+func (k *APIKey) PermissionNames() []string {
+	names := make([]string, 0, len(k.Permissions))
+	for _, permission := range k.Permissions {
+		names = append(names, permission.Name)
+	}
+	return names
+}
+
+// APIKeyGrant is what a request authenticated with an API key may do: the key's
+// permissions that the owner's role still grants
+type APIKeyGrant struct {
+	Key         *APIKey
+	User        *User
+	Permissions []string
+	Scopes      []Scope
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Audit event actions
+const (
+	AuditImpersonationStart   = "impersonation.start"
+	AuditImpersonationRequest = "impersonation.request"
+)
+
+// AuditEvent records something done to or on behalf of a user.
+// ActorID is who did it when that's someone other than the user, such as an
+// admin impersonating them.
+type AuditEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Action    string    `json:"action" gorm:"not null;size:50;index"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ActorID   *uint     `json:"actor_id" gorm:"index"`
+	Method    string    `json:"method,omitempty" gorm:"size:10"`
+	Path      string    `json:"path,omitempty" gorm:"size:255"`
+	Status    int       `json:"status,omitempty"`
+	IPAddress string    `json:"ip_address" gorm:"size:45"`
+	UserAgent string    `json:"user_agent" gorm:"size:255"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// TableName specifies the table name for GORM
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
@@ -11,6 +11,7 @@ type Book struct {
 	Title     string         `json:"title" gorm:"not null;size:255"`
 	Author    string         `json:"author" gorm:"not null;size:255"`
 	Quantity  int            `json:"quantity" gorm:"default:0"`
+	Available int            `json:"available" gorm:"default:0"` // copies on the shelf, not on loan or held for pickup
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -20,3 +21,8 @@ type Book struct {
 func (Book) TableName() string {
 	return "books"
 }
+
+// OnLoan returns the number of copies currently checked out or held for pickup
+func (b *Book) OnLoan() int {
+	return b.Quantity - b.Available
+}
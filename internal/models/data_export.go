@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// DataExportStatus represents the state of a personal data export
+type DataExportStatus string
+
+const (
+	DataExportPending DataExportStatus = "pending" // the archive is being built
+	DataExportReady   DataExportStatus = "ready"   // the archive can be downloaded
+	DataExportFailed  DataExportStatus = "failed"
+)
+
+// DataExport is an archive of everything stored about a user, built in the
+// background and downloadable until it expires. A user has at most one pending
+// export (idx_data_exports_pending_user).
+type DataExport struct {
+	ID          uint             `json:"id" gorm:"primaryKey"`
+	UserID      uint             `json:"user_id" gorm:"not null;index"`
+	Status      DataExportStatus `json:"status" gorm:"type:varchar(20);default:'pending';index"`
+	FilePath    string           `json:"-" gorm:"size:255"`
+	CompletedAt *time.Time       `json:"completed_at"`
+	ExpiresAt   *time.Time       `json:"expires_at" gorm:"index"` // set once finished; the export is deleted afterwards
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (DataExport) TableName() string {
+	return "data_exports"
+}
+
+// IsDownloadable checks if the archive is ready and not yet expired
+func (e *DataExport) IsDownloadable(now time.Time) bool {
+	return e.Status == DataExportReady && e.ExpiresAt != nil && now.Before(*e.ExpiresAt)
+}
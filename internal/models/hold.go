@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// HoldStatus represents the state of a hold in the waitlist
+type HoldStatus string
+
+const (
+	HoldWaiting   HoldStatus = "waiting"   // in the queue for a copy
+	HoldReady     HoldStatus = "ready"     // a copy is reserved for pickup
+	HoldFulfilled HoldStatus = "fulfilled" // the reserved copy was checked out
+	HoldCancelled HoldStatus = "cancelled"
+	HoldExpired   HoldStatus = "expired" // the pickup window lapsed
+)
+
+// Hold represents a user's place in the waitlist for a book with no copies available.
+// A user has at most one waiting or ready hold per book (idx_holds_active_user_book).
+type Hold struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	BookID    uint       `json:"book_id" gorm:"not null;index"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Status    HoldStatus `json:"status" gorm:"type:varchar(20);default:'waiting';index"`
+	ReadyAt   *time.Time `json:"ready_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Position  int        `json:"position,omitempty" gorm:"-"` // queue position while waiting
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Hold) TableName() string {
+	return "holds"
+}
+
+// IsActive checks if the hold is still waiting or ready for pickup
+func (h *Hold) IsActive() bool {
+	return h.Status == HoldWaiting || h.Status == HoldReady
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Invitation lets people register while registration is invite-only. The code is
+// only shown once, when the invitation is created.
+type Invitation struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Prefix      string     `json:"prefix" gorm:"not null;size:16"`        // start of the code, shown to tell invitations apart
+	CodeHash    string     `json:"-" gorm:"uniqueIndex;not null;size:64"` // SHA-256 of the code
+	Role        UserRole   `json:"role" gorm:"type:varchar(20);not null"` // role of the users registering with it
+	Email       *string    `json:"email" gorm:"size:100"`                 // if set, only this address can register with it
+	MaxUses     int        `json:"max_uses" gorm:"not null;default:1"`
+	Uses        int        `json:"uses" gorm:"not null;default:0"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	CreatedByID uint       `json:"created_by_id" gorm:"not null;index"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// IsUsable checks if the invitation can still be used to register
+func (i *Invitation) IsUsable(now time.Time) bool {
+	return i.RevokedAt == nil && i.Uses < i.MaxUses && now.Before(i.ExpiresAt)
+}
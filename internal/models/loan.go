@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Loan represents a copy of a book checked out by a user
+type Loan struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	BookID       uint       `json:"book_id" gorm:"not null;index"`
+	CheckedOutAt time.Time  `json:"checked_out_at" gorm:"not null"`
+	DueAt        time.Time  `json:"due_at" gorm:"not null;index"`
+	ReturnedAt   *time.Time `json:"returned_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Loan) TableName() string {
+	return "loans"
+}
+
+// IsActive checks if the loan has not been returned yet
+func (l *Loan) IsActive() bool {
+	return l.ReturnedAt == nil
+}
+
+// IsOverdue checks if the loan is still out past its due date
+func (l *Loan) IsOverdue(now time.Time) bool {
+	return l.IsActive() && now.After(l.DueAt)
+}
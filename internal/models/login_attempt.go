@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// LoginAttempt counts attempts made under a key, such as an account or a client IP,
+// within a window. A locked key is refused until LockedUntil.
+type LoginAttempt struct {
+	Key          string     `json:"-" gorm:"primaryKey;size:255"`
+	Count        int        `json:"count" gorm:"not null;default:0"`
+	WindowEndsAt time.Time  `json:"window_ends_at" gorm:"not null;index"`
+	LockedUntil  *time.Time `json:"locked_until"`
+}
+
+// TableName specifies the table name for GORM
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// IsLocked checks if attempts under the key are refused at now
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && now.Before(*a.LockedUntil)
+}
+
+// LockoutStatus reports an account's failed logins and whether it is locked
+type LockoutStatus struct {
+	Locked         bool       `json:"locked"`
+	FailedAttempts int        `json:"failed_attempts"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+}
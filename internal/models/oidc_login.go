@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// OIDCLogin is a sign-in started at the identity provider and not yet completed.
+// It holds the secrets the callback needs to finish the authorization code flow.
+type OIDCLogin struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	StateHash    string    `json:"-" gorm:"uniqueIndex;not null;size:64"` // SHA-256 of the state parameter
+	Nonce        string    `json:"-" gorm:"not null;size:64"`
+	CodeVerifier string    `json:"-" gorm:"not null;size:128"` // PKCE verifier
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (OIDCLogin) TableName() string {
+	return "oidc_logins"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PasswordHistory records a password hash a user has used before, to prevent reuse
+type PasswordHistory struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"not null;index"`
+	PasswordHash string    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// PasswordReset is a single-use token letting a user who forgot their password set a new one
+type PasswordReset struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null;size:64"` // SHA-256 of the emailed token
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PasswordReset) TableName() string {
+	return "password_resets"
+}
+
+// IsUsable checks if the token can still be redeemed
+func (p *PasswordReset) IsUsable(now time.Time) bool {
+	return p.UsedAt == nil && now.Before(p.ExpiresAt)
+}
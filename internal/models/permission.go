@@ -0,0 +1,77 @@
+package models
+
+// Permission is a single action a role can be allowed to perform
+type Permission struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name" gorm:"uniqueIndex;not null;size:50"`
+	Description string `json:"description" gorm:"size:255"`
+}
+
+// TableName specifies the table name for GORM
+func (Permission) TableName() string {
+	return "permissions"
+}
+
+const (
+	PermissionBookRead          = "book.read"
+	PermissionBookCreate        = "book.create"
+	PermissionBookUpdate        = "book.update"
+	PermissionBookDelete        = "book.delete"
+	PermissionInventoryAdjust   = "inventory.adjust"
+	PermissionLoanCreate        = "loan.create" // borrow, return and hold books for oneself
+	PermissionLoanManage        = "loan.manage" // return loans and cancel holds of other users
+	PermissionUserRead          = "user.read"
+	PermissionUserRoleUpdate    = "user.role.update"
+	PermissionUserSessionRevoke = "user.session.revoke"
+	PermissionUserUnlock        = "user.unlock"
+	PermissionUserImpersonate   = "user.impersonate"
+	PermissionUserSuspend       = "user.suspend" // suspend and reactivate accounts
+	PermissionUserDelete        = "user.delete"
+	PermissionInvitationManage  = "invitation.manage"
+	PermissionRoleManage        = "role.manage"
+)
+
+// BuiltInPermissions describes every permission the application checks
+var BuiltInPermissions = []Permission{
+	{Name: PermissionBookRead, Description: "View books"},
+	{Name: PermissionBookCreate, Description: "Add books to the catalog"},
+	{Name: PermissionBookUpdate, Description: "Edit book details"},
+	{Name: PermissionBookDelete, Description: "Remove books from the catalog"},
+	{Name: PermissionInventoryAdjust, Description: "Change the number of copies of a book"},
+	{Name: PermissionLoanCreate, Description: "Borrow, return and place holds on books"},
+	{Name: PermissionLoanManage, Description: "Manage other users' loans and holds"},
+	{Name: PermissionUserRead, Description: "View user accounts"},
+	{Name: PermissionUserRoleUpdate, Description: "Change a user's role"},
+	{Name: PermissionUserSessionRevoke, Description: "Revoke a user's sessions"},
+	{Name: PermissionUserUnlock, Description: "Lift a user's login lockout"},
+	{Name: PermissionUserImpersonate, Description: "Act as another user for support"},
+	{Name: PermissionUserSuspend, Description: "Suspend and reactivate user accounts"},
+	{Name: PermissionUserDelete, Description: "Delete user accounts"},
+	{Name: PermissionInvitationManage, Description: "Create and revoke registration invitations"},
+	{Name: PermissionRoleManage, Description: "Create roles and assign permissions"},
+}
+
+// BuiltInRoles describes the roles every deployment starts with and their default permissions.
+// The admin role always holds every permission.
+var BuiltInRoles = []Role{
+	{Name: RoleUser, Description: "Can view, borrow and return books"},
+	{Name: RoleLibrarian, Description: "Manages the catalog, stock and loans"},
+	{Name: RoleAdmin, Description: "Full access"},
+}
+
+// BuiltInRolePermissions lists the default permissions of the built-in roles other than admin
+var BuiltInRolePermissions = map[UserRole][]string{
+	RoleUser: {
+		PermissionBookRead,
+		PermissionLoanCreate,
+	},
+	RoleLibrarian: {
+		PermissionBookRead,
+		PermissionBookCreate,
+		PermissionBookUpdate,
+		PermissionInventoryAdjust,
+		PermissionLoanCreate,
+		PermissionLoanManage,
+		PermissionUserRead,
+	},
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RecoveryCode is a one-time code that stands in for a TOTP code when the user has lost their authenticator
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;size:64"` // SHA-256 of the normalized code
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RevokedToken is a deny-list entry for access tokens that must stop working before they expire
+type RevokedToken struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Identifier string    `json:"identifier" gorm:"uniqueIndex;not null;size:64"` // jti of one token or sid of a whole session
+	ExpiresAt  time.Time `json:"expires_at" gorm:"not null;index"`              // after this no matching token can still be valid
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Role is a named set of permissions that users are assigned by name (User.Role)
+type Role struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	Name        UserRole     `json:"name" gorm:"type:varchar(20);uniqueIndex;not null"`
+	Description string       `json:"description" gorm:"size:255"`
+	BuiltIn     bool         `json:"built_in" gorm:"not null;default:false"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Role) TableName() string {
+	return "roles"
+}
+
+// PermissionNames returns the names of the role's permissions
+func (r *Role) PermissionNames() []string {
+	names := make([]string, 0, len(r.Permissions))
+	for _, permission := range r.Permissions {
+		names = append(names, permission.Name)
+	}
+	return names
+}
@@ -0,0 +1,73 @@
+package models
+
+// Scope is a coarse permission carried in access tokens
+type Scope string
+
+const (
+	ScopeBooksRead  Scope = "books:read"  // browse the catalog
+	ScopeBooksWrite Scope = "books:write" // manage the catalog and stock
+	ScopeLoansWrite Scope = "loans:write" // borrow, return and place holds
+	ScopeUsersRead  Scope = "users:read"  // view user accounts
+	ScopeUsersAdmin Scope = "users:admin" // manage user accounts and roles
+)
+
+// permissionScopes maps each permission to the token scope that covers it
+var permissionScopes = map[string]Scope{
+	PermissionBookRead:          ScopeBooksRead,
+	PermissionBookCreate:        ScopeBooksWrite,
+	PermissionBookUpdate:        ScopeBooksWrite,
+	PermissionBookDelete:        ScopeBooksWrite,
+	PermissionInventoryAdjust:   ScopeBooksWrite,
+	PermissionLoanCreate:        ScopeLoansWrite,
+	PermissionLoanManage:        ScopeLoansWrite,
+	PermissionUserRead:          ScopeUsersRead,
+	PermissionUserRoleUpdate:    ScopeUsersAdmin,
+	PermissionUserSessionRevoke: ScopeUsersAdmin,
+	PermissionUserUnlock:        ScopeUsersAdmin,
+	PermissionUserImpersonate:   ScopeUsersAdmin,
+	PermissionUserSuspend:       ScopeUsersAdmin,
+	PermissionUserDelete:        ScopeUsersAdmin,
+	PermissionInvitationManage:  ScopeUsersAdmin,
+	PermissionRoleManage:        ScopeUsersAdmin,
+}
+
+// ScopeForPermission returns the token scope a permission falls under
+func ScopeForPermission(permission string) (Scope, bool) {
+	scope, ok := permissionScopes[permission]
+	return scope, ok
+}
+
+// ScopesForPermissions returns the scopes covering a set of permissions
+func ScopesForPermissions(permissions []string) []Scope {
+	scopes := []Scope{}
+	for _, permission := range permissions {
+		if scope, ok := permissionScopes[permission]; ok && !HasScope(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// HasScope checks if a scope is in the list
+func HasScope(scopes []Scope, scope Scope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// readScopes are the scopes that don't allow changing anything
+var readScopes = []Scope{ScopeBooksRead, ScopeUsersRead}
+
+// ReadOnlyScopes keeps only the scopes in the list that don't allow changing anything
+func ReadOnlyScopes(scopes []Scope) []Scope {
+	filtered := make([]Scope, 0, len(scopes))
+	for _, scope := range scopes {
+		if HasScope(readScopes, scope) {
+			filtered = append(filtered, scope)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Session represents one refresh token issued to a user.
+// Every refresh rotates the token into a new row of the same family; a family
+// is one login on one device.
+type Session struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	FamilyID     string     `json:"family_id" gorm:"not null;size:32;index"`
+	TokenHash    string     `json:"-" gorm:"uniqueIndex;not null;size:64"` // SHA-256 of the refresh token
+	TokenVersion uint       `json:"-" gorm:"not null;default:0"`           // user's token version when issued
+	Device       string     `json:"device" gorm:"size:100"`
+	UserAgent    string     `json:"user_agent" gorm:"size:255"`
+	IPAddress    string     `json:"ip_address" gorm:"size:45"`
+	TwoFactor    bool       `json:"two_factor" gorm:"not null;default:false"` // the login passed a second factor
+	StartedAt    time.Time  `json:"started_at" gorm:"not null"`               // when the family was created at login
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RotatedAt    *time.Time `json:"rotated_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	LastSeenAt   time.Time  `json:"last_seen_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// IsUsable checks if the refresh token can still be exchanged
+func (s *Session) IsUsable(now time.Time) bool {
+	return s.RotatedAt == nil && s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
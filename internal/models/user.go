@@ -5,24 +5,35 @@ import (
 	"gorm.io/gorm"
 )
 
-// UserRole represents user roles
+// UserRole is the name of the role (see Role) a user is assigned
 type UserRole string
 
 const (
-	RoleUser  UserRole = "user"
-	RoleAdmin UserRole = "admin"
+	RoleUser      UserRole = "user"
+	RoleLibrarian UserRole = "librarian"
+	RoleAdmin     UserRole = "admin"
 )
 
 // User represents a user in the system
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Username  string         `json:"username" gorm:"uniqueIndex;not null;size:50"`
-	Email     string         `json:"email" gorm:"uniqueIndex;not null;size:100"`
-	Password  string         `json:"-" gorm:"not null"` // "-" excludes from JSON
-	Role      UserRole       `json:"role" gorm:"type:varchar(20);default:'user'"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	Username         string         `json:"username" gorm:"uniqueIndex;not null;size:50"`
+	Email            string         `json:"email" gorm:"uniqueIndex;not null;size:100"`
+	EmailVerifiedAt  *time.Time     `json:"email_verified_at"`
+	Password         string         `json:"-" gorm:"not null"` // "-" excludes from JSON
+	Role             UserRole       `json:"role" gorm:"type:varchar(20);default:'user'"`
+	TokenVersion     uint           `json:"-" gorm:"not null;default:0"` // bumped to invalidate issued tokens
+	TwoFactorEnabled bool           `json:"two_factor_enabled" gorm:"not null;default:false"`
+	TOTPSecret       string         `json:"-" gorm:"size:64"`                                                         // set at enrollment, in use once TwoFactorEnabled
+	TOTPLastStep     int64          `json:"-" gorm:"not null;default:0"`                                              // last accepted time step, so codes can't be replayed
+	SuspendedAt      *time.Time     `json:"suspended_at" gorm:"index"`                                                // set while an admin has suspended the account
+	OIDCIssuer       *string        `json:"-" gorm:"column:oidc_issuer;size:255;uniqueIndex:idx_users_oidc_identity"` // identity provider account, if linked
+	OIDCSubject      *string        `json:"-" gorm:"column:oidc_subject;size:255;uniqueIndex:idx_users_oidc_identity"`
+	Lockout          *LockoutStatus `json:"lockout,omitempty" gorm:"-"` // failed logins, when requested
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
+	AnonymizedAt     *time.Time     `json:"-"` // personal data erased after the deletion grace period
 }
 
 // TableName specifies the table name for GORM
@@ -30,6 +41,16 @@ func (User) TableName() string {
 	return "users"
 }
 
+// IsEmailVerified checks if the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsSuspended checks if the user is kept from logging in and using the API
+func (u *User) IsSuspended() bool {
+	return u.SuspendedAt != nil
+}
+
 // IsAdmin checks if user has admin role
 func (u *User) IsAdmin() bool {
 	return u.Role == RoleAdmin
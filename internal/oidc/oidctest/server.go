@@ -0,0 +1,256 @@
+// Package oidctest provides an OpenID Connect provider for tests. It issues codes
+// for the authorization code flow with PKCE and RS256-signed ID tokens.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// keyID is the ID of the provider's signing key
+const keyID = "oidctest"
+
+// Server is a running test provider. Its issuer is its URL.
+type Server struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string // empty for a public client
+
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims map[string]interface{}
+	grants map[string]grant
+}
+
+// grant is an issued authorization code waiting to be redeemed
+type grant struct {
+	redirectURI   string
+	codeChallenge string
+	claims        jwt.MapClaims
+}
+
+// NewServer starts a provider with the given client registration. Close it when done.
+func NewServer(clientID, clientSecret string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("oidctest: generating key: " + err.Error())
+	}
+
+	s := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		grants:       make(map[string]grant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("/authorize", s.handleAuthorize)
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/jwks", s.handleKeys)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// SetClaims sets the claims of the ID tokens issued for later sign-ins, such as sub,
+// email and groups. They are added to, and may override, the standard claims.
+func (s *Server) SetClaims(claims map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims = claims
+}
+
+// Authorize signs in at an authorization URL from the relying party, as the user's
+// browser would, and returns the code and state it redirects back with
+func (s *Server) Authorize(authURL string) (code, state string, err error) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := parsed.Query()
+	code, err = s.authorize(query)
+	if err != nil {
+		return "", "", err
+	}
+	return code, query.Get("state"), nil
+}
+
+// SignIDToken signs claims as an ID token of this provider
+func (s *Server) SignIDToken(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		panic("oidctest: signing token: " + err.Error())
+	}
+	return signed
+}
+
+// authorize checks an authorization request and issues a code for it
+func (s *Server) authorize(query url.Values) (string, error) {
+	if query.Get("response_type") != "code" {
+		return "", errors.New("unsupported response_type")
+	}
+	if query.Get("client_id") != s.ClientID {
+		return "", errors.New("unknown client_id")
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		return "", errors.New("S256 code challenge required")
+	}
+
+	code, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": s.URL,
+		"aud": s.ClientID,
+		"sub": "user-1",
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+	if nonce := query.Get("nonce"); nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, value := range s.claims {
+		claims[name] = value
+	}
+	s.grants[code] = grant{
+		redirectURI:   query.Get("redirect_uri"),
+		codeChallenge: query.Get("code_challenge"),
+		claims:        claims,
+	}
+	return code, nil
+}
+
+// redeem checks a token request and returns the ID token for its code. Codes can be used once.
+func (s *Server) redeem(r *http.Request) (string, string) {
+	clientID, clientSecret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		return "", "invalid_client"
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		return "", "unsupported_grant_type"
+	}
+
+	s.mu.Lock()
+	code := r.PostForm.Get("code")
+	issued, ok := s.grants[code]
+	delete(s.grants, code)
+	s.mu.Unlock()
+
+	if !ok || issued.redirectURI != r.PostForm.Get("redirect_uri") {
+		return "", "invalid_grant"
+	}
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(verifier[:]) != issued.codeChallenge {
+		return "", "invalid_grant"
+	}
+	return s.SignIDToken(issued.claims), ""
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.URL,
+		"authorization_endpoint":                s.URL + "/authorize",
+		"token_endpoint":                        s.URL + "/token",
+		"jwks_uri":                              s.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+// handleAuthorize signs the user in without asking and redirects back with a code
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	code, err := s.authorize(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	idToken, errorCode := s.redeem(r)
+	if errorCode != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": errorCode})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	public := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// randomString returns a random hex string for codes
+func randomString() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
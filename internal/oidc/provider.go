@@ -0,0 +1,385 @@
+// Package oidc implements the relying party side of OpenID Connect: the
+// authorization code flow with PKCE and verification of ID tokens against the
+// provider's published keys.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config describes the identity provider and how this application is registered with it
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for a public client
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string // ID token claim listing the user's groups
+}
+
+// IDToken holds the verified claims of an ID token this application uses
+type IDToken struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	Groups            []string
+	AMR               []string // how the user authenticated at the provider
+}
+
+// discovery is the part of the provider's metadata document the flow needs
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider talks to one OpenID Connect provider. Its metadata and signing keys are
+// fetched on first use and cached for cacheTTL; the keys are also refetched when a
+// token names an unknown one. Fetches happen outside the lock, so a slow provider
+// only holds up the requests that need fresh data.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu              sync.Mutex
+	metadata        *discovery
+	metadataFetched time.Time
+	keys            map[string]interface{}
+	keysFetched     time.Time
+}
+
+const (
+	// cacheTTL is how long metadata and signing keys are used before they are refetched
+	cacheTTL = time.Hour
+	// keyRefreshInterval limits how often an unknown key ID triggers a JWKS refetch,
+	// and how soon a failed refresh is retried while the cached data is still used
+	keyRefreshInterval = time.Minute
+)
+
+// NewProvider creates a provider client
+func NewProvider(config Config) *Provider {
+	return &Provider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL returns the provider URL to send the user's browser to for signing in
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code at the token endpoint and returns the verified ID token
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDToken, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %d: %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return p.VerifyIDToken(ctx, token.IDToken, nonce)
+}
+
+// VerifyIDToken checks an ID token's signature, issuer, audience, expiry and nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDToken, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, metadata.JWKSURI, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	// With several audiences the token must have been issued to us
+	if azp, ok := claims["azp"].(string); ok && azp != p.config.ClientID {
+		return nil, errors.New("invalid ID token: issued to another client")
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+
+	idToken := &IDToken{
+		Issuer:            metadata.Issuer,
+		Email:             stringClaim(claims, "email"),
+		EmailVerified:     boolClaim(claims, "email_verified"),
+		Name:              stringClaim(claims, "name"),
+		PreferredUsername: stringClaim(claims, "preferred_username"),
+		Groups:            stringsClaim(claims, p.config.GroupsClaim),
+		AMR:               stringsClaim(claims, "amr"),
+	}
+	idToken.Subject, _ = claims.GetSubject()
+	if idToken.Subject == "" {
+		return nil, errors.New("invalid ID token: no subject")
+	}
+	return idToken, nil
+}
+
+// discover returns the provider metadata, fetching it when it isn't cached or is older than cacheTTL.
+// If a refresh fails, the cached metadata keeps being used and the refresh is retried later.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	metadata, fetched := p.metadata, p.metadataFetched
+	p.mu.Unlock()
+	if metadata != nil && time.Since(fetched) < cacheTTL {
+		return metadata, nil
+	}
+
+	fresh, err := p.fetchMetadata(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		if metadata == nil {
+			return nil, err
+		}
+		p.metadataFetched = retryAt(time.Now())
+		return metadata, nil
+	}
+	p.metadata, p.metadataFetched = fresh, time.Now()
+	return fresh, nil
+}
+
+// fetchMetadata downloads and checks the provider's metadata document
+func (p *Provider) fetchMetadata(ctx context.Context) (*discovery, error) {
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata discovery
+	status, err := p.doJSON(req, &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("fetching provider metadata: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetching provider metadata: status %d", status)
+	}
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("provider metadata is for issuer %q, expected %q", metadata.Issuer, p.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("provider metadata is missing endpoints")
+	}
+	return &metadata, nil
+}
+
+// key returns the provider's signing key with the given ID. The key set is refetched
+// when it is older than cacheTTL, or when the key is unknown, e.g. after the provider
+// rotated its keys. A cached key keeps being used if the refetch fails.
+func (p *Provider) key(ctx context.Context, jwksURI, kid string) (interface{}, error) {
+	p.mu.Lock()
+	key, found := p.lookupKey(kid)
+	age := time.Since(p.keysFetched)
+	p.mu.Unlock()
+
+	if found && age < cacheTTL {
+		return key, nil
+	}
+	if !found && age < keyRefreshInterval {
+		return nil, errors.New("unknown signing key")
+	}
+
+	keys, err := p.fetchKeys(ctx, jwksURI)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		if !found {
+			return nil, err
+		}
+		p.keysFetched = retryAt(time.Now())
+		return key, nil
+	}
+	p.keys, p.keysFetched = keys, time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// retryAt returns the fetch time to record after a failed refresh at now, so that
+// the cached data is used for keyRefreshInterval before the next attempt
+func retryAt(now time.Time) time.Time {
+	return now.Add(keyRefreshInterval - cacheTTL)
+}
+
+// lookupKey finds a cached key; tokens without a key ID match a sole key
+func (p *Provider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// fetchKeys downloads and parses the provider's JSON Web Key Set
+func (p *Provider) fetchKeys(ctx context.Context, jwksURI string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, fmt.Errorf("fetching provider keys: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetching provider keys: status %d", status)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue // skip key types we can't use rather than failing every login
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// doJSON sends a request and decodes the JSON response body, returning the status code
+func (p *Provider) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("invalid JSON from %s: %w", req.URL.Host, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// stringClaim returns a string claim or ""
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// boolClaim returns a boolean claim; some providers send booleans as strings
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
+
+// stringsClaim returns a claim holding a list of strings, or a single string
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	if name == "" {
+		return nil
+	}
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
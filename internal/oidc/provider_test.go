@@ -0,0 +1,162 @@
+package oidc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"example/go_api_tutorial/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newTestProvider starts a mock provider and a client registered with it
+func newTestProvider(t *testing.T, clientSecret string) (*oidctest.Server, *Provider) {
+	t.Helper()
+	server := oidctest.NewServer("library", clientSecret)
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(Config{
+		Issuer:       server.URL,
+		ClientID:     "library",
+		ClientSecret: clientSecret,
+		RedirectURL:  "http://localhost:8080/api/v1/auth/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+		GroupsClaim:  "groups",
+	})
+	return server, provider
+}
+
+// signIn starts a sign-in and returns the code the provider redirects back with
+func signIn(t *testing.T, server *oidctest.Server, provider *Provider, nonce, verifier string) string {
+	t.Helper()
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", nonce, CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, state, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != "state" {
+		t.Fatalf("state = %q, want %q", state, "state")
+	}
+	return code
+}
+
+func TestExchangeWithPKCE(t *testing.T) {
+	for _, secret := range []string{"", "s3cret&/="} {
+		server, provider := newTestProvider(t, secret)
+		server.SetClaims(map[string]interface{}{
+			"sub":            "alice-id",
+			"email":          "Alice@Example.com",
+			"email_verified": true,
+			"groups":         []string{"staff", "readers"},
+			"amr":            "mfa",
+		})
+
+		verifier, err := NewCodeVerifier()
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := signIn(t, server, provider, "nonce", verifier)
+
+		idToken, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+		if err != nil {
+			t.Fatalf("client secret %q: Exchange: %v", secret, err)
+		}
+		if idToken.Issuer != server.URL || idToken.Subject != "alice-id" || idToken.Email != "Alice@Example.com" || !idToken.EmailVerified {
+			t.Errorf("unexpected ID token %+v", idToken)
+		}
+		if strings.Join(idToken.Groups, ",") != "staff,readers" || strings.Join(idToken.AMR, ",") != "mfa" {
+			t.Errorf("groups %v and amr %v not read", idToken.Groups, idToken.AMR)
+		}
+
+		// Codes can only be redeemed once
+		if _, err := provider.Exchange(context.Background(), code, verifier, "nonce"); err == nil {
+			t.Error("reused code was accepted")
+		}
+	}
+}
+
+func TestExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	server, provider := newTestProvider(t, "")
+	verifier, _ := NewCodeVerifier()
+	other, _ := NewCodeVerifier()
+	code := signIn(t, server, provider, "nonce", verifier)
+
+	if _, err := provider.Exchange(context.Background(), code, other, "nonce"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Exchange with another verifier: err = %v, want invalid_grant", err)
+	}
+}
+
+func TestExchangeRejectsWrongNonce(t *testing.T) {
+	server, provider := newTestProvider(t, "")
+	verifier, _ := NewCodeVerifier()
+	code := signIn(t, server, provider, "nonce", verifier)
+
+	if _, err := provider.Exchange(context.Background(), code, verifier, "other-nonce"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("Exchange with another nonce: err = %v, want nonce mismatch", err)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	server, provider := newTestProvider(t, "")
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   server.URL,
+			"aud":   "library",
+			"sub":   "alice-id",
+			"nonce": "nonce",
+			"iat":   now.Unix(),
+			"exp":   now.Add(5 * time.Minute).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(claims jwt.MapClaims)
+		ok     bool
+	}{
+		{"valid", func(jwt.MapClaims) {}, true},
+		{"several audiences", func(c jwt.MapClaims) { c["aud"] = []string{"other", "library"}; c["azp"] = "library" }, true},
+		{"other issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, false},
+		{"other audience", func(c jwt.MapClaims) { c["aud"] = "other" }, false},
+		{"authorized party is another client", func(c jwt.MapClaims) { c["azp"] = "other" }, false},
+		{"wrong nonce", func(c jwt.MapClaims) { c["nonce"] = "replayed" }, false},
+		{"no nonce", func(c jwt.MapClaims) { delete(c, "nonce") }, false},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() }, false},
+		{"no expiry", func(c jwt.MapClaims) { delete(c, "exp") }, false},
+		{"no subject", func(c jwt.MapClaims) { delete(c, "sub") }, false},
+	}
+	for _, tt := range tests {
+		claims := valid()
+		tt.change(claims)
+		_, err := provider.VerifyIDToken(context.Background(), server.SignIDToken(claims), "nonce")
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%s: token was accepted", tt.name)
+		}
+	}
+}
+
+func TestVerifyIDTokenRejectsOtherSigner(t *testing.T) {
+	server, provider := newTestProvider(t, "")
+	impostor := oidctest.NewServer("library", "")
+	defer impostor.Close()
+
+	// Same key ID and issuer, but signed with the impostor's key
+	token := impostor.SignIDToken(jwt.MapClaims{
+		"iss":   server.URL,
+		"aud":   "library",
+		"sub":   "alice-id",
+		"nonce": "nonce",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	})
+	if _, err := provider.VerifyIDToken(context.Background(), token, "nonce"); err == nil {
+		t.Error("token signed by another key was accepted")
+	}
+}
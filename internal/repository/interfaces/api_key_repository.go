@@ -0,0 +1,26 @@
+package interfaces
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// APIKeyRepository defines the contract for API key data operations
+type APIKeyRepository interface {
+	// Create operations
+	Create(key *models.APIKey) error
+
+	// Read operations
+	// GetByHash returns the key with the given hash and its permissions
+	GetByHash(keyHash string) (*models.APIKey, error)
+	// GetByUser returns the unrevoked keys of a user and their permissions
+	GetByUser(userID uint) ([]models.APIKey, error)
+
+	// Update operations
+	// Revoke revokes one of a user's keys, reporting false if it doesn't exist or was already revoked
+	Revoke(id, userID uint) (bool, error)
+	// RevokeAllForUser revokes every unrevoked key of a user
+	RevokeAllForUser(userID uint) error
+	TouchLastUsed(id uint, usedAt time.Time) error
+}
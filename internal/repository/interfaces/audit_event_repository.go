@@ -0,0 +1,13 @@
+package interfaces
+
+import "example/go_api_tutorial/internal/models"
+
+// AuditEventRepository defines the contract for audit log data operations
+type AuditEventRepository interface {
+	// Create operations
+	Create(event *models.AuditEvent) error
+
+	// Read operations
+	// GetByUser returns the events done to a user or by them as an actor
+	GetByUser(userID uint) ([]models.AuditEvent, error)
+}
@@ -1,11 +1,21 @@
 package interfaces
 
-import "example/go_api_tutorial/internal/models"
+import (
+	"errors"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// ErrQuantityBelowOnLoan is returned when a quantity update would leave fewer
+// copies than are currently out on loan
+var ErrQuantityBelowOnLoan = errors.New("quantity below copies on loan")
 
 // BookRepository defines the contract for book data operations
 type BookRepository interface {
 	// Create operations
 	Create(book *models.Book) error
+	// CreateAll creates the books in one transaction: all of them or none
+	CreateAll(books []models.Book) error
 	
 	// Read operations
 	GetAll() ([]models.Book, error)
@@ -25,4 +35,8 @@ type BookRepository interface {
 	
 	// Pagination
 	GetPaginated(offset, limit int) ([]models.Book, int64, error)
+	
+	// Statistics
+	// Totals returns the number of titles and the total and available copies across them
+	Totals() (titles, copies, available int64, err error)
 }
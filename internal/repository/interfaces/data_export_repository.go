@@ -0,0 +1,35 @@
+package interfaces
+
+import (
+	"errors"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// ErrPendingExportExists is returned when creating an export while the user already
+// has one being built
+var ErrPendingExportExists = errors.New("pending export exists")
+
+// DataExportRepository defines the contract for personal data export operations
+type DataExportRepository interface {
+	// Create operations
+	// Create fails with ErrPendingExportExists if the user already has a pending export
+	Create(export *models.DataExport) error
+
+	// Read operations
+	GetByID(id uint) (*models.DataExport, error)
+	// GetPendingByUser returns the user's export still being built, if any
+	GetPendingByUser(userID uint) (*models.DataExport, error)
+	// GetLatestByUser returns the user's most recently requested export that didn't fail
+	GetLatestByUser(userID uint) (*models.DataExport, error)
+	// GetExpired returns finished exports past their expiry and pending ones started before staleBefore
+	GetExpired(now, staleBefore time.Time) ([]models.DataExport, error)
+
+	// Update operations
+	MarkReady(id uint, filePath string, completedAt, expiresAt time.Time) error
+	MarkFailed(id uint, completedAt, expiresAt time.Time) error
+
+	// Delete operations
+	Delete(id uint) error
+}
@@ -0,0 +1,44 @@
+package interfaces
+
+import (
+	"errors"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+var (
+	// ErrHoldNotActive is returned when a hold is no longer waiting or ready
+	ErrHoldNotActive = errors.New("hold not active")
+	// ErrActiveHoldExists is returned when creating a hold while the user already has
+	// a waiting or ready hold on the book
+	ErrActiveHoldExists = errors.New("active hold exists")
+)
+
+// HoldRepository defines the contract for hold (waitlist) data operations
+type HoldRepository interface {
+	// Create operations
+	// Create fails with ErrActiveHoldExists if the user already has an active hold on the book
+	Create(hold *models.Hold) error
+
+	// Read operations
+	GetByID(id uint) (*models.Hold, error)
+	GetActiveByUser(userID uint) ([]models.Hold, error)
+	// GetByUser returns all of a user's holds, including past ones
+	GetByUser(userID uint) ([]models.Hold, error)
+	GetActiveByUserAndBook(userID, bookID uint) (*models.Hold, error)
+	GetExpiredReady(now time.Time) ([]models.Hold, error)
+	QueuePosition(hold *models.Hold) (int64, error)
+	// CountActive returns how many holds are waiting in a queue and how many are ready for pickup
+	CountActive() (waiting, ready int64, err error)
+
+	// Update operations
+	// PromoteNext reserves an available copy for the oldest waiting hold on a book.
+	// It returns nil when there is no waiting hold or no copy to reserve.
+	PromoteNext(bookID uint, expiresAt time.Time) (*models.Hold, error)
+	// Cancel cancels a waiting or ready hold, releasing a reserved copy. It reports whether
+	// it released one, judged under the row lock since the hold may have become ready meanwhile.
+	Cancel(hold *models.Hold) (released bool, err error)
+	// Expire lapses a ready hold, releasing its reserved copy
+	Expire(hold *models.Hold) error
+}
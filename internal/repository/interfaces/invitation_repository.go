@@ -0,0 +1,27 @@
+package interfaces
+
+import (
+	"errors"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// ErrInvitationNotUsable is returned when redeeming an invitation that was used up, revoked or expired
+var ErrInvitationNotUsable = errors.New("invitation not usable")
+
+// InvitationRepository defines the contract for registration invitation data operations
+type InvitationRepository interface {
+	// Create operations
+	Create(invitation *models.Invitation) error
+	// Redeem uses up one use of the invitation and creates the user atomically;
+	// it fails with ErrInvitationNotUsable if the invitation can no longer be used
+	Redeem(invitationID uint, user *models.User) error
+
+	// Read operations
+	GetByCodeHash(codeHash string) (*models.Invitation, error)
+	GetAll() ([]models.Invitation, error)
+
+	// Update operations
+	// Revoke revokes an invitation, returning false if there is no unrevoked invitation with the ID
+	Revoke(id uint) (bool, error)
+}
@@ -0,0 +1,34 @@
+package interfaces
+
+import (
+	"errors"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+var (
+	// ErrNoCopiesAvailable is returned when a checkout finds no copy left to lend
+	ErrNoCopiesAvailable = errors.New("no copies available")
+	// ErrLoanAlreadyReturned is returned when a loan has already been closed
+	ErrLoanAlreadyReturned = errors.New("loan already returned")
+)
+
+// LoanRepository defines the contract for loan data operations
+type LoanRepository interface {
+	// Create operations
+	// Checkout takes one available copy of the book and records the loan atomically
+	Checkout(loan *models.Loan) error
+	// CheckoutHold fulfills the user's ready hold and records the loan for its reserved copy
+	CheckoutHold(loan *models.Loan, holdID uint) error
+
+	// Read operations
+	GetByID(id uint) (*models.Loan, error)
+	GetByUser(userID uint, activeOnly bool) ([]models.Loan, error)
+	// CountActive returns how many loans are out and how many of those are past due at now
+	CountActive(now time.Time) (active, overdue int64, err error)
+
+	// Update operations
+	// Return closes the loan and puts the copy back into circulation atomically
+	Return(loan *models.Loan, returnedAt time.Time) error
+}
@@ -0,0 +1,28 @@
+package interfaces
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// LoginAttemptStore defines the contract for counting login and registration attempts.
+// An in-memory store is enough for a single instance; deployments running several
+// instances need a shared store so limits apply across all of them.
+type LoginAttemptStore interface {
+	// Increment adds an attempt under key, starting a new window of the given
+	// length when there is no open one, and returns the updated counter
+	Increment(key string, window time.Duration, now time.Time) (*models.LoginAttempt, error)
+
+	// Get returns the open counter for key, or nil when there is none
+	Get(key string, now time.Time) (*models.LoginAttempt, error)
+
+	// Lock refuses attempts under key until the given time, keeping the counter at least that long
+	Lock(key string, until time.Time) error
+
+	// Reset clears the counter and any lock for key
+	Reset(key string) error
+
+	// DeleteExpired removes counters whose window has ended
+	DeleteExpired(now time.Time) error
+}
@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// OIDCLoginRepository defines the contract for pending identity provider sign-ins
+type OIDCLoginRepository interface {
+	// Create operations
+	Create(login *models.OIDCLogin) error
+
+	// Delete operations
+	// Consume removes and returns the sign-in with the given state hash, so it completes at most once
+	Consume(stateHash string) (*models.OIDCLogin, error)
+	DeleteExpired(now time.Time) error
+}
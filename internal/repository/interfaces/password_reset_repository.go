@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// PasswordResetRepository defines the contract for password reset token data operations
+type PasswordResetRepository interface {
+	// Create operations
+	Create(reset *models.PasswordReset) error
+
+	// Read operations
+	GetByTokenHash(tokenHash string) (*models.PasswordReset, error)
+
+	// Update operations
+	// MarkUsed redeems an unused token, returning false if it was already used
+	MarkUsed(id uint) (bool, error)
+	// InvalidateForUser marks every unused token of a user as used
+	InvalidateForUser(userID uint) error
+
+	// Delete operations
+	DeleteExpired(now time.Time) error
+}
@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// RevokedTokenRepository defines the contract for the token deny-list
+type RevokedTokenRepository interface {
+	// Create operations
+	Create(entry *models.RevokedToken) error
+
+	// Read operations
+	GetActive(now time.Time) ([]models.RevokedToken, error)
+
+	// Delete operations
+	DeleteExpired(now time.Time) error
+}
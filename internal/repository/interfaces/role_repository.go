@@ -0,0 +1,22 @@
+package interfaces
+
+import "example/go_api_tutorial/internal/models"
+
+// RoleRepository defines the contract for role and permission data operations
+type RoleRepository interface {
+	// Create operations
+	Create(role *models.Role) error
+
+	// Read operations
+	GetAll() ([]models.Role, error)
+	GetByName(name models.UserRole) (*models.Role, error)
+	GetAllPermissions() ([]models.Permission, error)
+	GetPermissionsByNames(names []string) ([]models.Permission, error)
+	CountUsersWithRole(name models.UserRole) (int64, error)
+
+	// Update operations
+	SetPermissions(role *models.Role, permissions []models.Permission) error
+
+	// Delete operations
+	Delete(role *models.Role) error
+}
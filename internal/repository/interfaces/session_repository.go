@@ -0,0 +1,31 @@
+package interfaces
+
+import (
+	"errors"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// ErrSessionNotUsable is returned when rotating a refresh token that was already rotated or revoked
+var ErrSessionNotUsable = errors.New("session not usable")
+
+// SessionRepository defines the contract for refresh token session data operations
+type SessionRepository interface {
+	// Create operations
+	Create(session *models.Session) error
+
+	// Read operations
+	GetByTokenHash(tokenHash string) (*models.Session, error)
+	// GetActiveByUser returns the current (unrotated, unrevoked, unexpired) token of each of the user's logins
+	GetActiveByUser(userID uint) ([]models.Session, error)
+	// GetByUser returns every refresh token ever issued to a user, including rotated and revoked ones
+	GetByUser(userID uint) ([]models.Session, error)
+	// GetLiveFamilyIDs returns the families of a user whose access tokens may still be in use
+	GetLiveFamilyIDs(userID uint) ([]string, error)
+
+	// Update operations
+	// Rotate marks the current token used and stores its replacement atomically
+	Rotate(current *models.Session, next *models.Session) error
+	RevokeFamily(familyID string) error
+	RevokeAllForUser(userID uint) error
+}
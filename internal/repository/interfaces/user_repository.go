@@ -1,6 +1,39 @@
 package interfaces
 
-import "example/go_api_tutorial/internal/models"
+import (
+	"errors"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// User statuses a listing can be filtered by
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+)
+
+// UserFilter narrows, orders and pages a user listing. Zero values don't filter.
+type UserFilter struct {
+	Role          models.UserRole
+	Status        string // UserStatusActive or UserStatusSuspended
+	Search        string // substring of the username or email, case insensitive
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	SortBy        string // id, username, email or created_at
+	Descending    bool
+	Offset        int
+	Limit         int
+}
+
+// ErrLastAdmin is returned when demoting, suspending or deleting the only active admin
+var ErrLastAdmin = errors.New("last active admin")
+
+// ErrTwoFactorEnabled is returned when re-enrolling a user who already has 2FA on
+var ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+
+// ErrUsernameOrEmailTaken is returned when another user already has the username or email address
+var ErrUsernameOrEmailTaken = errors.New("username or email taken")
 
 // UserRepository defines the contract for user data operations
 type UserRepository interface {
@@ -9,18 +42,66 @@ type UserRepository interface {
 	
 	// Read operations
 	GetAll() ([]models.User, error)
+	// Search returns a page of users matching the filter and the number of matches
+	Search(filter UserFilter) ([]models.User, int64, error)
 	GetByID(id uint) (*models.User, error)
+	GetByIDWithCredentials(id uint) (*models.User, error)
 	GetByUsername(username string) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
+	// GetByOIDCIdentity returns the user linked to an identity provider account
+	GetByOIDCIdentity(issuer, subject string) (*models.User, error)
 	
 	// Update operations
 	Update(user *models.User) error
-	UpdatePassword(id uint, hashedPassword string) error
+	// RehashPassword replaces the password hash with an equivalent one, only if it is still
+	// currentHash, so a password changed meanwhile isn't overwritten. It reports whether it did.
+	RehashPassword(id uint, currentHash, newHash string) (bool, error)
+	// UpdateRole changes a user's role; demoting the last active admin fails with ErrLastAdmin
+	UpdateRole(id uint, role models.UserRole) error
+	// UpdateProfile changes the username and email address together; nil leaves one as is.
+	// A new email address needs verifying again. It fails with ErrUsernameOrEmailTaken
+	// if another user has either.
+	UpdateProfile(id uint, username, email *string) error
+	// Suspend suspends an active user and invalidates their tokens, returning false if already suspended.
+	// Suspending the last active admin fails with ErrLastAdmin.
+	Suspend(id uint, suspendedAt time.Time) (bool, error)
+	// Reactivate lifts a suspension, returning false if the user wasn't suspended
+	Reactivate(id uint) (bool, error)
+	// InvalidateTokens bumps the token version so every token issued so far stops working
+	InvalidateTokens(id uint) error
+	// ChangePassword stores a new password hash, records the old one in the password
+	// history (keeping at most historySize entries), invalidates issued tokens and
+	// revokes the user's API keys
+	ChangePassword(id uint, hashedPassword, previousHash string, historySize int) error
+	// MarkEmailVerified records that the user confirmed the given address, returning
+	// false if it is no longer their address or was already verified
+	MarkEmailVerified(id uint, email string) (bool, error)
+	// SetTOTPSecret stores a pending TOTP secret; it fails with ErrTwoFactorEnabled once 2FA is on
+	SetTOTPSecret(id uint, secret string) error
+	// EnableTwoFactor turns 2FA on if the pending secret is still the given one and
+	// replaces the user's recovery codes; it returns gorm.ErrRecordNotFound otherwise
+	EnableTwoFactor(id uint, secret string, recoveryCodeHashes []string) error
+	// UseTOTPStep records a TOTP time step as used, returning false if it or a later one already was
+	UseTOTPStep(id uint, step int64) (bool, error)
+	// UseRecoveryCode marks an unused recovery code as used, returning false if there was none
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	// LinkOIDCIdentity links an identity provider account to a user, returning false
+	// if the user is already linked to an account
+	LinkOIDCIdentity(id uint, issuer, subject string) (bool, error)
 	
 	// Delete operations
-	Delete(id uint) error
+	// Delete soft deletes a user and cancels their active holds in one transaction, returning
+	// the books whose reserved copies went back on the shelf. Deleting the last active admin
+	// fails with ErrLastAdmin and changes nothing.
+	Delete(id uint) (releasedBooks []uint, err error)
+	// GetDeletedBefore returns up to limit users soft deleted before the cutoff whose data is still present
+	GetDeletedBefore(cutoff time.Time, limit int) ([]uint, error)
+	// Anonymize erases a deleted user's personal data and credentials, freeing their username and email
+	Anonymize(id uint) error
 	
 	// Authentication helpers
+	// ExistsByUsername and ExistsByEmail include deleted users not yet anonymized
 	ExistsByUsername(username string) (bool, error)
 	ExistsByEmail(email string) (bool, error)
+	GetPasswordHistory(userID uint, limit int) ([]string, error)
 }
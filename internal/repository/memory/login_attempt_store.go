@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+)
+
+// loginAttemptStore implements the LoginAttemptStore interface in process memory.
+// Counters are lost on restart and not shared between instances.
+type loginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]*models.LoginAttempt
+}
+
+// NewLoginAttemptStore creates a new in-memory login attempt store
+func NewLoginAttemptStore() interfaces.LoginAttemptStore {
+	return &loginAttemptStore{attempts: make(map[string]*models.LoginAttempt)}
+}
+
+// Increment adds an attempt under key, starting a new window when the last one has ended
+func (s *loginAttemptStore) Increment(key string, window time.Duration, now time.Time) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok || !now.Before(attempt.WindowEndsAt) {
+		attempt = &models.LoginAttempt{Key: key, WindowEndsAt: now.Add(window)}
+		s.attempts[key] = attempt
+	}
+	attempt.Count++
+
+	copied := *attempt
+	return &copied, nil
+}
+
+// Get returns the open counter for key, or nil when there is none
+func (s *loginAttemptStore) Get(key string, now time.Time) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok || !now.Before(attempt.WindowEndsAt) {
+		return nil, nil
+	}
+
+	copied := *attempt
+	return &copied, nil
+}
+
+// Lock refuses attempts under key until the given time
+func (s *loginAttemptStore) Lock(key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt = &models.LoginAttempt{Key: key}
+		s.attempts[key] = attempt
+	}
+	attempt.LockedUntil = &until
+	if attempt.WindowEndsAt.Before(until) {
+		attempt.WindowEndsAt = until
+	}
+	return nil
+}
+
+// Reset clears the counter and any lock for key
+func (s *loginAttemptStore) Reset(key string) error {
+	s.mu.Lock()
+	delete(s.attempts, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// DeleteExpired removes counters whose window has ended
+func (s *loginAttemptStore) DeleteExpired(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, attempt := range s.attempts {
+		if !now.Before(attempt.WindowEndsAt) {
+			delete(s.attempts, key)
+		}
+	}
+	return nil
+}
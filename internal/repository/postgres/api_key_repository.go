@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// apiKeyRepository implements the APIKeyRepository interface
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *gorm.DB) interfaces.APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// Create creates a new API key with its permissions
+func (r *apiKeyRepository) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+// GetByHash returns the key with the given hash and its permissions
+func (r *apiKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Preload("Permissions").Where("key_hash = ?", keyHash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByUser returns the unrevoked keys of a user, newest first
+func (r *apiKeyRepository) GetByUser(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.db.Preload("Permissions").
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Revoke revokes one of a user's keys
+func (r *apiKeyRepository) Revoke(id, userID uint) (bool, error) {
+	result := r.db.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAllForUser revokes every unrevoked key of a user
+func (r *apiKeyRepository) RevokeAllForUser(userID uint) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// TouchLastUsed records when a key was last used
+func (r *apiKeyRepository) TouchLastUsed(id uint, usedAt time.Time) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).Error
+}
@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// auditEventRepository implements the AuditEventRepository interface
+type auditEventRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditEventRepository creates a new audit event repository
+func NewAuditEventRepository(db *gorm.DB) interfaces.AuditEventRepository {
+	return &auditEventRepository{db: db}
+}
+
+// Create appends an event to the audit log
+func (r *auditEventRepository) Create(event *models.AuditEvent) error {
+	return r.db.Create(event).Error
+}
+
+// GetByUser returns the events where the user is the subject or the actor, oldest first
+func (r *auditEventRepository) GetByUser(userID uint) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := r.db.Where("user_id = ? OR actor_id = ?", userID, userID).Order("id").Find(&events).Error
+	return events, err
+}
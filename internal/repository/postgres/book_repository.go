@@ -21,6 +21,16 @@ func (r *bookRepository) Create(book *models.Book) error {
 	return r.db.Create(book).Error
 }
 
+// CreateAll creates several books in one transaction
+func (r *bookRepository) CreateAll(books []models.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(&books, 100).Error
+	})
+}
+
 // GetAll returns all books
 func (r *bookRepository) GetAll() ([]models.Book, error) {
 	var books []models.Book
@@ -52,14 +62,28 @@ func (r *bookRepository) GetByAuthor(author string) ([]models.Book, error) {
 	return books, err
 }
 
-// Update updates a book
+// Update updates a book's details (stock levels are changed through UpdateQuantity)
 func (r *bookRepository) Update(book *models.Book) error {
-	return r.db.Save(book).Error
+	return r.db.Omit("quantity", "available").Save(book).Error
 }
 
-// UpdateQuantity updates only the quantity of a book
+// UpdateQuantity sets the total number of copies of a book, keeping the copies
+// currently out on loan unavailable. The check and the write happen in a single
+// statement so a concurrent checkout cannot slip in between them.
 func (r *bookRepository) UpdateQuantity(id uint, quantity int) error {
-	return r.db.Model(&models.Book{}).Where("id = ?", id).Update("quantity", quantity).Error
+	result := r.db.Model(&models.Book{}).
+		Where("id = ? AND quantity - available <= ?", id, quantity).
+		Updates(map[string]interface{}{
+			"available": gorm.Expr("? - (quantity - available)", quantity),
+			"quantity":  quantity,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return interfaces.ErrQuantityBelowOnLoan
+	}
+	return nil
 }
 
 // Delete soft deletes a book
@@ -89,3 +113,16 @@ func (r *bookRepository) GetPaginated(offset, limit int) ([]models.Book, int64,
 	err := r.db.Offset(offset).Limit(limit).Find(&books).Error
 	return books, total, err
 }
+
+// Totals sums the copies of all books in a single query
+func (r *bookRepository) Totals() (titles, copies, available int64, err error) {
+	var totals struct {
+		Titles    int64
+		Copies    int64
+		Available int64
+	}
+	err = r.db.Model(&models.Book{}).
+		Select("COUNT(*) AS titles, COALESCE(SUM(quantity), 0) AS copies, COALESCE(SUM(available), 0) AS available").
+		Scan(&totals).Error
+	return totals.Titles, totals.Copies, totals.Available, err
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"errors"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// dataExportRepository implements the DataExportRepository interface
+type dataExportRepository struct {
+	db *gorm.DB
+}
+
+// NewDataExportRepository creates a new data export repository
+func NewDataExportRepository(db *gorm.DB) interfaces.DataExportRepository {
+	return &dataExportRepository{db: db}
+}
+
+// Create records a new export
+func (r *dataExportRepository) Create(export *models.DataExport) error {
+	err := r.db.Create(export).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return interfaces.ErrPendingExportExists
+	}
+	return err
+}
+
+// GetByID returns an export by ID
+func (r *dataExportRepository) GetByID(id uint) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.db.First(&export, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// GetPendingByUser returns the user's oldest export still being built
+func (r *dataExportRepository) GetPendingByUser(userID uint) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.db.Where("user_id = ? AND status = ?", userID, models.DataExportPending).
+		Order("id").First(&export).Error
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// GetLatestByUser returns the user's newest export that is pending or ready
+func (r *dataExportRepository) GetLatestByUser(userID uint) (*models.DataExport, error) {
+	var export models.DataExport
+	err := r.db.Where("user_id = ? AND status <> ?", userID, models.DataExportFailed).
+		Order("created_at DESC, id DESC").First(&export).Error
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// GetExpired returns exports to clean up: finished ones past their expiry and pending
+// ones started before staleBefore, whose build was interrupted
+func (r *dataExportRepository) GetExpired(now, staleBefore time.Time) ([]models.DataExport, error) {
+	var exports []models.DataExport
+	err := r.db.Where("(status <> ? AND expires_at <= ?) OR (status = ? AND created_at < ?)",
+		models.DataExportPending, now, models.DataExportPending, staleBefore).
+		Find(&exports).Error
+	return exports, err
+}
+
+// MarkReady records where the finished archive is and until when it can be downloaded
+func (r *dataExportRepository) MarkReady(id uint, filePath string, completedAt, expiresAt time.Time) error {
+	return r.db.Model(&models.DataExport{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       models.DataExportReady,
+		"file_path":    filePath,
+		"completed_at": completedAt,
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+// MarkFailed records that an archive couldn't be built, keeping the record until expiresAt
+func (r *dataExportRepository) MarkFailed(id uint, completedAt, expiresAt time.Time) error {
+	return r.db.Model(&models.DataExport{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       models.DataExportFailed,
+		"completed_at": completedAt,
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+// Delete removes an export record
+func (r *dataExportRepository) Delete(id uint) error {
+	return r.db.Delete(&models.DataExport{}, id).Error
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+)
+
+func TestCreateSecondPendingExport(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewDataExportRepository(db)
+	const userID = 1 << 30
+	t.Cleanup(func() { db.Where("user_id = ?", userID).Delete(&models.DataExport{}) })
+
+	first := &models.DataExport{UserID: userID, Status: models.DataExportPending}
+	if err := repo.Create(first); err != nil {
+		t.Fatal(err)
+	}
+	second := &models.DataExport{UserID: userID, Status: models.DataExportPending}
+	if err := repo.Create(second); !errors.Is(err, interfaces.ErrPendingExportExists) {
+		t.Fatalf("second pending export: err = %v, want ErrPendingExportExists", err)
+	}
+
+	// Once the first is built, another may start
+	now := time.Now()
+	if err := repo.MarkReady(first.ID, "export.zip", now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Create(second); err != nil {
+		t.Errorf("pending export after the first finished: %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package postgres
+
+import (
+	"errors"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// holdRepository implements the HoldRepository interface
+type holdRepository struct {
+	db *gorm.DB
+}
+
+// NewHoldRepository creates a new hold repository
+func NewHoldRepository(db *gorm.DB) interfaces.HoldRepository {
+	return &holdRepository{db: db}
+}
+
+// Create creates a new hold. A unique index on active holds rejects a second one
+// for the same user and book, even when both are placed at once.
+func (r *holdRepository) Create(hold *models.Hold) error {
+	err := r.db.Create(hold).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return interfaces.ErrActiveHoldExists
+	}
+	return err
+}
+
+// GetByID returns a hold by ID
+func (r *holdRepository) GetByID(id uint) (*models.Hold, error) {
+	var hold models.Hold
+	err := r.db.First(&hold, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// GetActiveByUser returns a user's waiting and ready holds, oldest first
+func (r *holdRepository) GetActiveByUser(userID uint) ([]models.Hold, error) {
+	var holds []models.Hold
+	err := r.db.Where("user_id = ? AND status IN ?", userID, []models.HoldStatus{models.HoldWaiting, models.HoldReady}).
+		Order("id").Find(&holds).Error
+	return holds, err
+}
+
+// GetByUser returns every hold a user has placed, newest first
+func (r *holdRepository) GetByUser(userID uint) ([]models.Hold, error) {
+	var holds []models.Hold
+	err := r.db.Where("user_id = ?", userID).Order("id DESC").Find(&holds).Error
+	return holds, err
+}
+
+// GetActiveByUserAndBook returns a user's waiting or ready hold on a book
+func (r *holdRepository) GetActiveByUserAndBook(userID, bookID uint) (*models.Hold, error) {
+	var hold models.Hold
+	err := r.db.Where("user_id = ? AND book_id = ? AND status IN ?", userID, bookID, []models.HoldStatus{models.HoldWaiting, models.HoldReady}).
+		First(&hold).Error
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// GetExpiredReady returns ready holds whose pickup window has passed
+func (r *holdRepository) GetExpiredReady(now time.Time) ([]models.Hold, error) {
+	var holds []models.Hold
+	err := r.db.Where("status = ? AND expires_at < ?", models.HoldReady, now).Find(&holds).Error
+	return holds, err
+}
+
+// QueuePosition returns the 1-based position of a waiting hold in its book's queue
+func (r *holdRepository) QueuePosition(hold *models.Hold) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Hold{}).
+		Where("book_id = ? AND status = ? AND id <= ?", hold.BookID, models.HoldWaiting, hold.ID).
+		Count(&count).Error
+	return count, err
+}
+
+// CountActive counts the waiting and the ready holds
+func (r *holdRepository) CountActive() (waiting, ready int64, err error) {
+	if err = r.db.Model(&models.Hold{}).Where("status = ?", models.HoldWaiting).Count(&waiting).Error; err != nil {
+		return 0, 0, err
+	}
+	err = r.db.Model(&models.Hold{}).Where("status = ?", models.HoldReady).Count(&ready).Error
+	return waiting, ready, err
+}
+
+// PromoteNext moves the oldest waiting hold to ready and takes a copy off the shelf for it
+func (r *holdRepository) PromoteNext(bookID uint, expiresAt time.Time) (*models.Hold, error) {
+	var promoted *models.Hold
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var hold models.Hold
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("book_id = ? AND status = ?", bookID, models.HoldWaiting).
+			Order("id").First(&hold).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Book{}).
+			Where("id = ? AND available > 0", bookID).
+			Update("available", gorm.Expr("available - 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		err = tx.Model(&hold).Updates(map[string]interface{}{
+			"status":     models.HoldReady,
+			"ready_at":   now,
+			"expires_at": expiresAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		promoted = &hold
+		return nil
+	})
+	return promoted, err
+}
+
+// Cancel cancels a waiting or ready hold
+func (r *holdRepository) Cancel(hold *models.Hold) (bool, error) {
+	released := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Hold
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, hold.ID).Error
+		if err != nil {
+			return err
+		}
+		if !current.IsActive() {
+			return interfaces.ErrHoldNotActive
+		}
+
+		if err := tx.Model(&current).Update("status", models.HoldCancelled).Error; err != nil {
+			return err
+		}
+		if current.Status == models.HoldReady {
+			if err := releaseCopy(tx, current.BookID); err != nil {
+				return err
+			}
+			released = true
+		}
+
+		hold.Status = models.HoldCancelled
+		return nil
+	})
+	return released && err == nil, err
+}
+
+// Expire lapses a ready hold whose pickup window has passed
+func (r *holdRepository) Expire(hold *models.Hold) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Hold{}).
+			Where("id = ? AND status = ?", hold.ID, models.HoldReady).
+			Update("status", models.HoldExpired)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return interfaces.ErrHoldNotActive
+		}
+
+		if err := releaseCopy(tx, hold.BookID); err != nil {
+			return err
+		}
+
+		hold.Status = models.HoldExpired
+		return nil
+	})
+}
+
+// releaseCopy puts a copy of a book back on the shelf.
+// Unscoped so copies of a since-deleted book are still accounted for.
+func releaseCopy(tx *gorm.DB, bookID uint) error {
+	return tx.Unscoped().Model(&models.Book{}).
+		Where("id = ? AND available < quantity", bookID).
+		Update("available", gorm.Expr("available + 1")).Error
+}
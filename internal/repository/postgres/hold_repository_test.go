@@ -0,0 +1,190 @@
+package postgres
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// createWaitingHolds queues a hold on the book for each user, in order
+func createWaitingHolds(t *testing.T, repo interfaces.HoldRepository, bookID uint, userIDs ...uint) []*models.Hold {
+	t.Helper()
+	var holds []*models.Hold
+	for _, userID := range userIDs {
+		hold := &models.Hold{BookID: bookID, UserID: userID, Status: models.HoldWaiting}
+		if err := repo.Create(hold); err != nil {
+			t.Fatal(err)
+		}
+		holds = append(holds, hold)
+	}
+	return holds
+}
+
+// setAvailable puts copies of a book back on the shelf behind the repositories' back
+func setAvailable(t *testing.T, db *gorm.DB, bookID uint, available int) {
+	t.Helper()
+	if err := db.Model(&models.Book{}).Where("id = ?", bookID).Update("available", available).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// holdStatus reads a hold's status from the database
+func holdStatus(t *testing.T, db *gorm.DB, holdID uint) models.HoldStatus {
+	t.Helper()
+	var hold models.Hold
+	if err := db.First(&hold, holdID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return hold.Status
+}
+
+func TestPromoteNextInQueueOrder(t *testing.T) {
+	db := openTestDB(t)
+	book := createTestBook(t, db, 2)
+	setAvailable(t, db, book.ID, 0)
+	repo := NewHoldRepository(db)
+	holds := createWaitingHolds(t, repo, book.ID, 1, 2, 3)
+
+	// Two copies come back: the two oldest holds get them, in order
+	setAvailable(t, db, book.ID, 2)
+	for _, want := range holds[:2] {
+		promoted, err := repo.PromoteNext(book.ID, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if promoted == nil || promoted.ID != want.ID {
+			t.Fatalf("promoted %+v, want hold %d of user %d", promoted, want.ID, want.UserID)
+		}
+	}
+
+	promoted, err := repo.PromoteNext(book.ID, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if promoted != nil {
+		t.Errorf("promoted hold %d without a copy for it", promoted.ID)
+	}
+	if status := holdStatus(t, db, holds[2].ID); status != models.HoldWaiting {
+		t.Errorf("third hold is %s, want waiting", status)
+	}
+	if available := availableCopies(t, db, book.ID); available != 0 {
+		t.Errorf("available = %d, want 0", available)
+	}
+}
+
+func TestPromoteNextConcurrently(t *testing.T) {
+	db := openTestDB(t)
+	book := createTestBook(t, db, 2)
+	setAvailable(t, db, book.ID, 0)
+	repo := NewHoldRepository(db)
+	createWaitingHolds(t, repo, book.ID, 1, 2, 3, 4, 5)
+	setAvailable(t, db, book.ID, 2)
+
+	// Promoters skip holds locked by each other, and only as many as there are copies win
+	const promoters = 5
+	promoted := make(chan *models.Hold, promoters)
+	var wg sync.WaitGroup
+	for i := 0; i < promoters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hold, err := repo.PromoteNext(book.ID, time.Now().Add(time.Hour))
+			if err != nil {
+				t.Errorf("PromoteNext: %v", err)
+			}
+			promoted <- hold
+		}()
+	}
+	wg.Wait()
+	close(promoted)
+
+	seen := make(map[uint]bool)
+	for hold := range promoted {
+		if hold == nil {
+			continue
+		}
+		if seen[hold.ID] {
+			t.Errorf("hold %d promoted twice", hold.ID)
+		}
+		seen[hold.ID] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("%d holds promoted for 2 copies", len(seen))
+	}
+	if available := availableCopies(t, db, book.ID); available != 0 {
+		t.Errorf("available = %d, want 0", available)
+	}
+}
+
+func TestCancelReadyHoldReleasesCopy(t *testing.T) {
+	db := openTestDB(t)
+	book := createTestBook(t, db, 1)
+	setAvailable(t, db, book.ID, 0)
+	repo := NewHoldRepository(db)
+	hold := createWaitingHolds(t, repo, book.ID, 1)[0]
+
+	// The caller read the hold while it was waiting; it became ready before cancelling
+	stale := *hold
+	setAvailable(t, db, book.ID, 1)
+	if promoted, err := repo.PromoteNext(book.ID, time.Now().Add(time.Hour)); err != nil || promoted == nil {
+		t.Fatalf("PromoteNext = %v, %v", promoted, err)
+	}
+
+	released, err := repo.Cancel(&stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !released {
+		t.Error("Cancel didn't report releasing the copy reserved for the hold")
+	}
+	if available := availableCopies(t, db, book.ID); available != 1 {
+		t.Errorf("available = %d, want 1", available)
+	}
+	if status := holdStatus(t, db, hold.ID); status != models.HoldCancelled {
+		t.Errorf("hold is %s, want cancelled", status)
+	}
+
+	if _, err := repo.Cancel(hold); !errors.Is(err, interfaces.ErrHoldNotActive) {
+		t.Errorf("cancelling again: err = %v, want ErrHoldNotActive", err)
+	}
+	if available := availableCopies(t, db, book.ID); available != 1 {
+		t.Errorf("available = %d after cancelling again, want 1", available)
+	}
+}
+
+func TestCancelWaitingHoldKeepsCopies(t *testing.T) {
+	db := openTestDB(t)
+	book := createTestBook(t, db, 1)
+	setAvailable(t, db, book.ID, 0)
+	repo := NewHoldRepository(db)
+	hold := createWaitingHolds(t, repo, book.ID, 1)[0]
+
+	released, err := repo.Cancel(hold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released {
+		t.Error("Cancel reported releasing a copy for a waiting hold")
+	}
+	if available := availableCopies(t, db, book.ID); available != 0 {
+		t.Errorf("available = %d, want 0", available)
+	}
+}
+
+func TestCheckoutRefusedWhileHoldsWait(t *testing.T) {
+	db := openTestDB(t)
+	book := createTestBook(t, db, 1)
+	setAvailable(t, db, book.ID, 0)
+	createWaitingHolds(t, NewHoldRepository(db), book.ID, 1)
+
+	// A returned copy belongs to the queue, not to whoever asks first
+	setAvailable(t, db, book.ID, 1)
+	if err := NewLoanRepository(db).Checkout(newTestLoan(2, book.ID)); !errors.Is(err, interfaces.ErrNoCopiesAvailable) {
+		t.Errorf("Checkout: err = %v, want ErrNoCopiesAvailable", err)
+	}
+}
@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// invitationRepository implements the InvitationRepository interface
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+// NewInvitationRepository creates a new invitation repository
+func NewInvitationRepository(db *gorm.DB) interfaces.InvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+// Create creates a new invitation
+func (r *invitationRepository) Create(invitation *models.Invitation) error {
+	return r.db.Create(invitation).Error
+}
+
+// Redeem counts a use of the invitation and creates the user in one transaction,
+// so concurrent registrations can't use it more often than allowed
+func (r *invitationRepository) Redeem(invitationID uint, user *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND revoked_at IS NULL AND uses < max_uses AND expires_at > ?", invitationID, time.Now()).
+			Update("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return interfaces.ErrInvitationNotUsable
+		}
+
+		return tx.Create(user).Error
+	})
+}
+
+// GetByCodeHash returns the invitation with the given code hash
+func (r *invitationRepository) GetByCodeHash(codeHash string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.db.Where("code_hash = ?", codeHash).First(&invitation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// GetAll returns every invitation, newest first
+func (r *invitationRepository) GetAll() ([]models.Invitation, error) {
+	var invitations []models.Invitation
+	err := r.db.Order("created_at DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+// Revoke revokes an invitation
+func (r *invitationRepository) Revoke(id uint) (bool, error) {
+	result := r.db.Model(&models.Invitation{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// loanRepository implements the LoanRepository interface
+type loanRepository struct {
+	db *gorm.DB
+}
+
+// NewLoanRepository creates a new loan repository
+func NewLoanRepository(db *gorm.DB) interfaces.LoanRepository {
+	return &loanRepository{db: db}
+}
+
+// Checkout decrements the available copies and creates the loan in one transaction.
+// The conditional update means concurrent checkouts can never drive availability below zero,
+// and a copy is never taken while someone is still waiting in the hold queue for it.
+func (r *loanRepository) Checkout(loan *models.Loan) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Book{}).
+			Where("id = ? AND available > 0", loan.BookID).
+			Where("NOT EXISTS (SELECT 1 FROM holds WHERE holds.book_id = books.id AND holds.status = ?)", models.HoldWaiting).
+			Update("available", gorm.Expr("available - 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return interfaces.ErrNoCopiesAvailable
+		}
+
+		return tx.Create(loan).Error
+	})
+}
+
+// CheckoutHold fulfills a ready hold and creates the loan for the copy it reserved
+func (r *loanRepository) CheckoutHold(loan *models.Loan, holdID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Hold{}).
+			Where("id = ? AND user_id = ? AND book_id = ? AND status = ?", holdID, loan.UserID, loan.BookID, models.HoldReady).
+			Update("status", models.HoldFulfilled)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return interfaces.ErrHoldNotActive
+		}
+
+		return tx.Create(loan).Error
+	})
+}
+
+// GetByID returns a loan by ID
+func (r *loanRepository) GetByID(id uint) (*models.Loan, error) {
+	var loan models.Loan
+	err := r.db.First(&loan, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// GetByUser returns the loans of a user, newest first
+func (r *loanRepository) GetByUser(userID uint, activeOnly bool) ([]models.Loan, error) {
+	var loans []models.Loan
+	query := r.db.Where("user_id = ?", userID)
+	if activeOnly {
+		query = query.Where("returned_at IS NULL")
+	}
+	err := query.Order("checked_out_at DESC").Find(&loans).Error
+	return loans, err
+}
+
+// CountActive counts the loans not returned yet, and those of them due before now
+func (r *loanRepository) CountActive(now time.Time) (active, overdue int64, err error) {
+	if err = r.db.Model(&models.Loan{}).Where("returned_at IS NULL").Count(&active).Error; err != nil {
+		return 0, 0, err
+	}
+	err = r.db.Model(&models.Loan{}).Where("returned_at IS NULL AND due_at < ?", now).Count(&overdue).Error
+	return active, overdue, err
+}
+
+// Return marks the loan returned and increments the available copies in one transaction
+func (r *loanRepository) Return(loan *models.Loan, returnedAt time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Loan{}).
+			Where("id = ? AND returned_at IS NULL", loan.ID).
+			Update("returned_at", returnedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return interfaces.ErrLoanAlreadyReturned
+		}
+
+		if err := releaseCopy(tx, loan.BookID); err != nil {
+			return err
+		}
+
+		loan.ReturnedAt = &returnedAt
+		return nil
+	})
+}
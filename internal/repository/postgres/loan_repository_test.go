@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+)
+
+// newTestLoan returns a two week loan of a book
+func newTestLoan(userID, bookID uint) *models.Loan {
+	now := time.Now()
+	return &models.Loan{UserID: userID, BookID: bookID, CheckedOutAt: now, DueAt: now.Add(14 * 24 * time.Hour)}
+}
+
+func TestCheckoutLastCopyConcurrently(t *testing.T) {
+	db := openTestDB(t)
+	book := createTestBook(t, db, 1)
+	repo := NewLoanRepository(db)
+
+	const borrowers = 10
+	errs := make(chan error, borrowers)
+	var wg sync.WaitGroup
+	for userID := uint(1); userID <= borrowers; userID++ {
+		wg.Add(1)
+		go func(userID uint) {
+			defer wg.Done()
+			errs <- repo.Checkout(newTestLoan(userID, book.ID))
+		}(userID)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, interfaces.ErrNoCopiesAvailable):
+			t.Errorf("Checkout: unexpected error %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d checkouts of the last copy succeeded, want 1", succeeded)
+	}
+	if available := availableCopies(t, db, book.ID); available != 0 {
+		t.Errorf("available = %d, want 0", available)
+	}
+
+	var loans int64
+	db.Model(&models.Loan{}).Where("book_id = ?", book.ID).Count(&loans)
+	if loans != 1 {
+		t.Errorf("%d loans created, want 1", loans)
+	}
+}
+
+func TestCheckoutWithNoCopiesLeft(t *testing.T) {
+	db := openTestDB(t)
+	book := createTestBook(t, db, 0)
+	repo := NewLoanRepository(db)
+
+	if err := repo.Checkout(newTestLoan(1, book.ID)); !errors.Is(err, interfaces.ErrNoCopiesAvailable) {
+		t.Errorf("Checkout: err = %v, want ErrNoCopiesAvailable", err)
+	}
+	if available := availableCopies(t, db, book.ID); available != 0 {
+		t.Errorf("available = %d, want 0", available)
+	}
+}
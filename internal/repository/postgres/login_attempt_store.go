@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"errors"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// loginAttemptStore implements the LoginAttemptStore interface on a table shared by every instance
+type loginAttemptStore struct {
+	db *gorm.DB
+}
+
+// NewLoginAttemptStore creates a new PostgreSQL-backed login attempt store
+func NewLoginAttemptStore(db *gorm.DB) interfaces.LoginAttemptStore {
+	return &loginAttemptStore{db: db}
+}
+
+// Increment adds an attempt under key in a single statement, so concurrent attempts
+// from several instances are all counted. A counter whose window has ended starts over.
+func (s *loginAttemptStore) Increment(key string, window time.Duration, now time.Time) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := s.db.Raw(`
+		INSERT INTO login_attempts (key, count, window_ends_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN login_attempts.window_ends_at <= ? THEN 1 ELSE login_attempts.count + 1 END,
+			locked_until = CASE WHEN login_attempts.window_ends_at <= ? THEN NULL ELSE login_attempts.locked_until END,
+			window_ends_at = CASE WHEN login_attempts.window_ends_at <= ? THEN EXCLUDED.window_ends_at ELSE login_attempts.window_ends_at END
+		RETURNING key, count, window_ends_at, locked_until`,
+		key, now.Add(window), now, now, now,
+	).Scan(&attempt).Error
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// Get returns the open counter for key, or nil when there is none
+func (s *loginAttemptStore) Get(key string, now time.Time) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := s.db.Where("key = ? AND window_ends_at > ?", key, now).First(&attempt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// Lock refuses attempts under key until the given time
+func (s *loginAttemptStore) Lock(key string, until time.Time) error {
+	return s.db.Exec(`
+		INSERT INTO login_attempts (key, count, window_ends_at, locked_until) VALUES (?, 0, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			locked_until = EXCLUDED.locked_until,
+			window_ends_at = GREATEST(login_attempts.window_ends_at, EXCLUDED.window_ends_at)`,
+		key, until, until,
+	).Error
+}
+
+// Reset clears the counter and any lock for key
+func (s *loginAttemptStore) Reset(key string) error {
+	return s.db.Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
+
+// DeleteExpired removes counters whose window has ended
+func (s *loginAttemptStore) DeleteExpired(now time.Time) error {
+	return s.db.Where("window_ends_at <= ?", now).Delete(&models.LoginAttempt{}).Error
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// oidcLoginRepository implements the OIDCLoginRepository interface
+type oidcLoginRepository struct {
+	db *gorm.DB
+}
+
+// NewOIDCLoginRepository creates a new OIDC login repository
+func NewOIDCLoginRepository(db *gorm.DB) interfaces.OIDCLoginRepository {
+	return &oidcLoginRepository{db: db}
+}
+
+// Create stores a pending sign-in
+func (r *oidcLoginRepository) Create(login *models.OIDCLogin) error {
+	return r.db.Create(login).Error
+}
+
+// Consume deletes the pending sign-in with the given state hash and returns it.
+// Of several concurrent callbacks with the same state only one gets the row.
+func (r *oidcLoginRepository) Consume(stateHash string) (*models.OIDCLogin, error) {
+	var logins []models.OIDCLogin
+	err := r.db.Clauses(clause.Returning{}).Where("state_hash = ?", stateHash).Delete(&logins).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(logins) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &logins[0], nil
+}
+
+// DeleteExpired removes sign-ins that were never completed
+func (r *oidcLoginRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&models.OIDCLogin{}).Error
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// passwordResetRepository implements the PasswordResetRepository interface
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+// NewPasswordResetRepository creates a new password reset repository
+func NewPasswordResetRepository(db *gorm.DB) interfaces.PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+// Create stores a new reset token
+func (r *passwordResetRepository) Create(reset *models.PasswordReset) error {
+	return r.db.Create(reset).Error
+}
+
+// GetByTokenHash returns the reset token with the given hash
+func (r *passwordResetRepository) GetByTokenHash(tokenHash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := r.db.Where("token_hash = ?", tokenHash).First(&reset).Error
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// MarkUsed redeems a token. The conditional update lets only one of several
+// concurrent requests with the same token succeed.
+func (r *passwordResetRepository) MarkUsed(id uint) (bool, error) {
+	result := r.db.Model(&models.PasswordReset{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// InvalidateForUser marks every unused token of a user as used
+func (r *passwordResetRepository) InvalidateForUser(userID uint) error {
+	return r.db.Model(&models.PasswordReset{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// DeleteExpired removes tokens that can no longer be redeemed
+func (r *passwordResetRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&models.PasswordReset{}).Error
+}
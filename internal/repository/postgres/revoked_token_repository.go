@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// revokedTokenRepository implements the RevokedTokenRepository interface
+type revokedTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRevokedTokenRepository creates a new revoked token repository
+func NewRevokedTokenRepository(db *gorm.DB) interfaces.RevokedTokenRepository {
+	return &revokedTokenRepository{db: db}
+}
+
+// Create adds a deny-list entry, ignoring identifiers that are already revoked
+func (r *revokedTokenRepository) Create(entry *models.RevokedToken) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(entry).Error
+}
+
+// GetActive returns the deny-list entries that have not expired yet
+func (r *revokedTokenRepository) GetActive(now time.Time) ([]models.RevokedToken, error) {
+	var entries []models.RevokedToken
+	err := r.db.Where("expires_at > ?", now).Find(&entries).Error
+	return entries, err
+}
+
+// DeleteExpired removes deny-list entries no longer needed
+func (r *revokedTokenRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error
+}
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// roleRepository implements the RoleRepository interface
+type roleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new role repository
+func NewRoleRepository(db *gorm.DB) interfaces.RoleRepository {
+	return &roleRepository{db: db}
+}
+
+// Create creates a new role with its permissions
+func (r *roleRepository) Create(role *models.Role) error {
+	return r.db.Create(role).Error
+}
+
+// GetAll returns all roles with their permissions
+func (r *roleRepository) GetAll() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Preload("Permissions").Order("id").Find(&roles).Error
+	return roles, err
+}
+
+// GetByName returns a role by name with its permissions
+func (r *roleRepository) GetByName(name models.UserRole) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Preload("Permissions").Where("name = ?", name).First(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// GetAllPermissions returns every known permission
+func (r *roleRepository) GetAllPermissions() ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// GetPermissionsByNames returns the permissions with the given names
+func (r *roleRepository) GetPermissionsByNames(names []string) ([]models.Permission, error) {
+	var permissions []models.Permission
+	err := r.db.Where("name IN ?", names).Find(&permissions).Error
+	return permissions, err
+}
+
+// CountUsersWithRole returns how many users are assigned a role
+func (r *roleRepository) CountUsersWithRole(name models.UserRole) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("role = ?", name).Count(&count).Error
+	return count, err
+}
+
+// SetPermissions replaces the permissions of a role
+func (r *roleRepository) SetPermissions(role *models.Role, permissions []models.Permission) error {
+	return r.db.Model(role).Association("Permissions").Replace(permissions)
+}
+
+// Delete deletes a role and its permission assignments
+func (r *roleRepository) Delete(role *models.Role) error {
+	return r.db.Select("Permissions").Delete(role).Error
+}
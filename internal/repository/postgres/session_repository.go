@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// sessionRepository implements the SessionRepository interface
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db *gorm.DB) interfaces.SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// Create creates a new session
+func (r *sessionRepository) Create(session *models.Session) error {
+	return r.db.Create(session).Error
+}
+
+// GetByTokenHash returns the session holding a refresh token hash
+func (r *sessionRepository) GetByTokenHash(tokenHash string) (*models.Session, error) {
+	var session models.Session
+	err := r.db.Where("token_hash = ?", tokenHash).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetActiveByUser returns the usable token of each of a user's logins, most recently used first
+func (r *sessionRepository) GetActiveByUser(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// GetByUser returns all of a user's refresh tokens, newest first
+func (r *sessionRepository) GetByUser(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.db.Where("user_id = ?", userID).Order("id DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// GetLiveFamilyIDs returns the distinct families of a user that are neither revoked nor expired
+func (r *sessionRepository) GetLiveFamilyIDs(userID uint) ([]string, error) {
+	var familyIDs []string
+	err := r.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Distinct().Pluck("family_id", &familyIDs).Error
+	return familyIDs, err
+}
+
+// Rotate marks the current token rotated and creates the next one in the same transaction.
+// The conditional update means a token can only ever be rotated once, even under concurrent refreshes.
+func (r *sessionRepository) Rotate(current *models.Session, next *models.Session) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("rotated_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return interfaces.ErrSessionNotUsable
+		}
+
+		return tx.Create(next).Error
+	})
+}
+
+// RevokeFamily revokes every token rotated from the same login
+func (r *sessionRepository) RevokeFamily(familyID string) error {
+	return r.db.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser revokes every session of a user
+func (r *sessionRepository) RevokeAllForUser(userID uint) error {
+	return r.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -1,11 +1,28 @@
 package postgres
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"example/go_api_tutorial/internal/models"
 	"example/go_api_tutorial/internal/repository/interfaces"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// userColumns are the columns of users loaded without credentials
+var userColumns = []string{"id", "username", "email", "email_verified_at", "role", "token_version", "two_factor_enabled", "suspended_at", "created_at", "updated_at"}
+
+// userSortColumns maps the sort fields of a user listing to columns
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"email":      "email",
+	"created_at": "created_at",
+}
+
 // userRepository implements the UserRepository interface
 type userRepository struct {
 	db *gorm.DB
@@ -24,14 +41,80 @@ func (r *userRepository) Create(user *models.User) error {
 // GetAll returns all users (excluding password)
 func (r *userRepository) GetAll() ([]models.User, error) {
 	var users []models.User
-	err := r.db.Select("id", "username", "email", "role", "created_at", "updated_at").Find(&users).Error
+	err := r.db.Select(userColumns).Find(&users).Error
 	return users, err
 }
 
+// Search returns a page of users matching the filter (excluding password)
+func (r *userRepository) Search(filter interfaces.UserFilter) ([]models.User, int64, error) {
+	var total int64
+	if err := r.db.Model(&models.User{}).Scopes(matchingUsers(filter)).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := userSortColumns[filter.SortBy]
+	if !ok {
+		column = "id"
+	}
+	direction := "ASC"
+	if filter.Descending {
+		direction = "DESC"
+	}
+	// Ties are broken by ID so pages don't overlap
+	order := column + " " + direction
+	if column != "id" {
+		order += ", id " + direction
+	}
+
+	var users []models.User
+	err := r.db.Select(userColumns).Scopes(matchingUsers(filter)).
+		Order(order).Offset(filter.Offset).Limit(filter.Limit).Find(&users).Error
+	return users, total, err
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern so text is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// matchingUsers applies the conditions of a user filter
+func matchingUsers(filter interfaces.UserFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.Role != "" {
+			db = db.Where("role = ?", filter.Role)
+		}
+		switch filter.Status {
+		case interfaces.UserStatusActive:
+			db = db.Where("suspended_at IS NULL")
+		case interfaces.UserStatusSuspended:
+			db = db.Where("suspended_at IS NOT NULL")
+		}
+		if filter.Search != "" {
+			pattern := "%" + likeEscaper.Replace(filter.Search) + "%"
+			db = db.Where("username ILIKE ? OR email ILIKE ?", pattern, pattern)
+		}
+		if filter.CreatedAfter != nil {
+			db = db.Where("created_at >= ?", *filter.CreatedAfter)
+		}
+		if filter.CreatedBefore != nil {
+			db = db.Where("created_at < ?", *filter.CreatedBefore)
+		}
+		return db
+	}
+}
+
 // GetByID returns a user by ID (excluding password)
 func (r *userRepository) GetByID(id uint) (*models.User, error) {
 	var user models.User
-	err := r.db.Select("id", "username", "email", "role", "created_at", "updated_at").First(&user, id).Error
+	err := r.db.Select(userColumns).First(&user, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetByIDWithCredentials returns a user by ID including the password hash
+func (r *userRepository) GetByIDWithCredentials(id uint) (*models.User, error) {
+	var user models.User
+	err := r.db.First(&user, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -58,31 +141,347 @@ func (r *userRepository) GetByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+// GetByOIDCIdentity returns the user linked to an identity provider account (including password for authentication)
+func (r *userRepository) GetByOIDCIdentity(issuer, subject string) (*models.User, error) {
+	var user models.User
+	err := r.db.Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 // Update updates a user
 func (r *userRepository) Update(user *models.User) error {
 	return r.db.Save(user).Error
 }
 
-// UpdatePassword updates only the password of a user
-func (r *userRepository) UpdatePassword(id uint, hashedPassword string) error {
-	return r.db.Model(&models.User{}).Where("id = ?", id).Update("password", hashedPassword).Error
+// RehashPassword replaces the password hash if it is still currentHash
+func (r *userRepository) RehashPassword(id uint, currentHash, newHash string) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND password = ?", id, currentHash).
+		Update("password", newHash)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateRole updates only the role of a user, refusing to demote the last active admin
+func (r *userRepository) UpdateRole(id uint, role models.UserRole) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if role != models.RoleAdmin {
+			if err := keepAnAdmin(tx, id); err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&models.User{}).Where("id = ?", id).Update("role", role)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// keepAnAdmin fails with ErrLastAdmin if the user is the only active admin. It locks the
+// rows of the active admins until the transaction ends, so concurrent demotions,
+// suspensions and deletions of different admins can't together remove them all.
+func keepAnAdmin(tx *gorm.DB, id uint) error {
+	var adminIDs []uint
+	err := tx.Model(&models.User{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("role = ? AND suspended_at IS NULL", models.RoleAdmin).
+		Pluck("id", &adminIDs).Error
+	if err != nil {
+		return err
+	}
+
+	if len(adminIDs) == 1 && adminIDs[0] == id {
+		return interfaces.ErrLastAdmin
+	}
+	return nil
+}
+
+// UpdateProfile changes the username and email address in one statement, clearing the
+// email verification when the address changes
+func (r *userRepository) UpdateProfile(id uint, username, email *string) error {
+	updates := make(map[string]interface{})
+	if username != nil {
+		updates["username"] = *username
+	}
+	if email != nil {
+		updates["email"] = *email
+		updates["email_verified_at"] = nil
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	err := r.db.Model(&models.User{}).Where("id = ?", id).Updates(updates).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return interfaces.ErrUsernameOrEmailTaken
+	}
+	return err
+}
+
+// Suspend marks an active user suspended and bumps the token version, ending their logins
+func (r *userRepository) Suspend(id uint, suspendedAt time.Time) (bool, error) {
+	suspended := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := keepAnAdmin(tx, id); err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND suspended_at IS NULL", id).
+			Updates(map[string]interface{}{
+				"suspended_at":  suspendedAt,
+				"token_version": gorm.Expr("token_version + 1"),
+			})
+		suspended = result.RowsAffected > 0
+		return result.Error
+	})
+	return suspended, err
+}
+
+// Reactivate clears a user's suspension
+func (r *userRepository) Reactivate(id uint) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND suspended_at IS NOT NULL", id).
+		Update("suspended_at", nil)
+	return result.RowsAffected > 0, result.Error
 }
 
-// Delete soft deletes a user
-func (r *userRepository) Delete(id uint) error {
-	return r.db.Delete(&models.User{}, id).Error
+// InvalidateTokens bumps a user's token version
+func (r *userRepository) InvalidateTokens(id uint) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).
+		Update("token_version", gorm.Expr("token_version + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
-// ExistsByUsername checks if a user exists by username
+// ChangePassword updates the password, records the previous hash, bumps the token version
+// and revokes the user's API keys, which a leaked password may have been used to create
+func (r *userRepository) ChangePassword(id uint, hashedPassword, previousHash string, historySize int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"password":      hashedPassword,
+			"token_version": gorm.Expr("token_version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		err := tx.Model(&models.APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		if historySize <= 0 {
+			return nil
+		}
+
+		entry := &models.PasswordHistory{UserID: id, PasswordHash: previousHash}
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+
+		// Keep only the most recent entries
+		return tx.Where("user_id = ? AND id NOT IN (?)", id,
+			tx.Model(&models.PasswordHistory{}).Select("id").Where("user_id = ?", id).Order("id DESC").Limit(historySize),
+		).Delete(&models.PasswordHistory{}).Error
+	})
+}
+
+// MarkEmailVerified records that the user confirmed their current email address
+func (r *userRepository) MarkEmailVerified(id uint, email string) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", id, email).
+		Update("email_verified_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// SetTOTPSecret stores a pending TOTP secret for a user without 2FA
+func (r *userRepository) SetTOTPSecret(id uint, secret string) error {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND two_factor_enabled = ?", id, false).
+		Update("totp_secret", secret)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return interfaces.ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// EnableTwoFactor turns 2FA on for the verified secret and replaces the user's recovery codes
+func (r *userRepository) EnableTwoFactor(id uint, secret string, recoveryCodeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND totp_secret = ?", id, secret).
+			Update("two_factor_enabled", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]models.RecoveryCode, 0, len(recoveryCodeHashes))
+		for _, hash := range recoveryCodeHashes {
+			codes = append(codes, models.RecoveryCode{UserID: id, CodeHash: hash})
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseTOTPStep records a TOTP time step as used unless it or a later one already was
+func (r *userRepository) UseTOTPStep(id uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// UseRecoveryCode marks an unused recovery code as used
+func (r *userRepository) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// LinkOIDCIdentity links an identity provider account to a user that has none yet
+func (r *userRepository) LinkOIDCIdentity(id uint, issuer, subject string) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND oidc_subject IS NULL", id).
+		Updates(map[string]interface{}{"oidc_issuer": issuer, "oidc_subject": subject})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Delete soft deletes a user and cancels their active holds
+func (r *userRepository) Delete(id uint) ([]uint, error) {
+	var released []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := keepAnAdmin(tx, id); err != nil {
+			return err
+		}
+
+		var holds []models.Hold
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status IN ?", id, []models.HoldStatus{models.HoldWaiting, models.HoldReady}).
+			Find(&holds).Error
+		if err != nil {
+			return err
+		}
+		for _, hold := range holds {
+			err := tx.Model(&models.Hold{}).Where("id = ?", hold.ID).Update("status", models.HoldCancelled).Error
+			if err != nil {
+				return err
+			}
+			if hold.Status == models.HoldReady {
+				if err := releaseCopy(tx, hold.BookID); err != nil {
+					return err
+				}
+				released = append(released, hold.BookID)
+			}
+		}
+
+		return tx.Delete(&models.User{}, id).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+// GetDeletedBefore returns the IDs of users deleted before the cutoff and not yet anonymized
+func (r *userRepository) GetDeletedBefore(cutoff time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := r.db.Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ? AND anonymized_at IS NULL", cutoff).
+		Order("deleted_at").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Anonymize replaces a deleted user's personal data with placeholders and removes their
+// credentials and login history. Loans and audit events stay, tied to the anonymous row.
+func (r *userRepository) Anonymize(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		placeholder := fmt.Sprintf("deleted-%d", id)
+		result := tx.Unscoped().Model(&models.User{}).
+			Where("id = ? AND deleted_at IS NOT NULL AND anonymized_at IS NULL", id).
+			Updates(map[string]interface{}{
+				"username":           placeholder,
+				"email":              placeholder + "@invalid",
+				"email_verified_at":  nil,
+				"password":           "",
+				"token_version":      gorm.Expr("token_version + 1"),
+				"two_factor_enabled": false,
+				"totp_secret":        "",
+				"oidc_issuer":        nil,
+				"oidc_subject":       nil,
+				"anonymized_at":      time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Exec("DELETE FROM api_key_permissions WHERE api_key_id IN (SELECT id FROM api_keys WHERE user_id = ?)", id).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.PasswordHistory{}, &models.RecoveryCode{}, &models.Session{}, &models.PasswordReset{}, &models.APIKey{}} {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExistsByUsername checks if a username is taken, including by a deleted user not yet anonymized
 func (r *userRepository) ExistsByUsername(username string) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.User{}).Where("username = ?", username).Count(&count).Error
+	err := r.db.Unscoped().Model(&models.User{}).Where("username = ?", username).Count(&count).Error
 	return count > 0, err
 }
 
-// ExistsByEmail checks if a user exists by email
+// ExistsByEmail checks if an email is taken, including by a deleted user not yet anonymized
 func (r *userRepository) ExistsByEmail(email string) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.User{}).Where("email = ?", email).Count(&count).Error
+	err := r.db.Unscoped().Model(&models.User{}).Where("email = ?", email).Count(&count).Error
 	return count > 0, err
 }
+
+// GetPasswordHistory returns the most recent previous password hashes of a user
+func (r *userRepository) GetPasswordHistory(userID uint, limit int) ([]string, error) {
+	var hashes []string
+	err := r.db.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Pluck("password_hash", &hashes).Error
+	return hashes, err
+}
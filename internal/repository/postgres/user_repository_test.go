@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"sync"
+	"testing"
+
+	"example/go_api_tutorial/internal/models"
+	"gorm.io/gorm/schema"
+)
+
+// The queries and migrations name the identity provider columns oidc_issuer and
+// oidc_subject, which GORM's default naming would spell o_id_c_issuer and o_id_c_subject
+func TestUserOIDCColumnNames(t *testing.T) {
+	userSchema, err := schema.Parse(&models.User{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, column := range map[string]string{"OIDCIssuer": "oidc_issuer", "OIDCSubject": "oidc_subject"} {
+		if f := userSchema.LookUpField(field); f == nil || f.DBName != column {
+			t.Errorf("%s is not stored in column %s", field, column)
+		}
+	}
+}
+
+func TestLikeEscaperMatchesLiterally(t *testing.T) {
+	for text, want := range map[string]string{
+		"alice":     "alice",
+		"100%":      `100\%`,
+		"a_b":       `a\_b`,
+		`back\path`: `back\\path`,
+	} {
+		if got := likeEscaper.Replace(text); got != want {
+			t.Errorf("likeEscaper.Replace(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// anonymizeBatchSize is how many deleted users AnonymizeDeleted handles per query
+const anonymizeBatchSize = 100
+
+// AccountService handles closing user accounts. Deleted accounts are kept for a
+// grace period, then their personal data is erased and their username and email freed.
+type AccountService struct {
+	userRepo       interfaces.UserRepository
+	userService    *UserService
+	sessionService *SessionService
+	loanService    *LoanService
+	holdService    *HoldService
+	gracePeriod    time.Duration
+}
+
+// NewAccountService creates a new account service.
+// gracePeriod is how long deleted accounts are kept before they are anonymized.
+func NewAccountService(userRepo interfaces.UserRepository, userService *UserService, sessionService *SessionService, loanService *LoanService, holdService *HoldService, gracePeriod time.Duration) *AccountService {
+	return &AccountService{
+		userRepo:       userRepo,
+		userService:    userService,
+		sessionService: sessionService,
+		loanService:    loanService,
+		holdService:    holdService,
+		gracePeriod:    gracePeriod,
+	}
+}
+
+// DeleteAccount deletes the user's own account after they confirm their identity with
+// their password or, if they have none, a recent sign-in
+func (s *AccountService) DeleteAccount(userID uint, password string, signedInAt time.Time) error {
+	user, err := s.userRepo.GetByIDWithCredentials(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		return err
+	}
+
+	if err := s.userService.confirmIdentity(user, password, signedInAt); err != nil {
+		return err
+	}
+
+	return s.deleteUser(userID)
+}
+
+// DeleteUser deletes another user's account (admin only). It is kept for the grace
+// period like an account deleted by its owner. Admins delete their own with DeleteAccount.
+func (s *AccountService) DeleteUser(actorID, userID uint) error {
+	if actorID == userID {
+		return errors.New("cannot delete your own account")
+	}
+	if _, err := s.userService.GetUserByID(userID); err != nil {
+		return err
+	}
+	return s.deleteUser(userID)
+}
+
+// deleteUser soft deletes a user who has no books out, cancelling their holds and
+// ending their logins
+func (s *AccountService) deleteUser(userID uint) error {
+	loans, err := s.loanService.GetUserLoans(userID, true)
+	if err != nil {
+		return err
+	}
+	if len(loans) > 0 {
+		return errors.New("all borrowed books must be returned first")
+	}
+
+	// The holds are cancelled in the same transaction, so a refused deletion keeps them
+	releasedBooks, err := s.userRepo.Delete(userID)
+	if err != nil {
+		if errors.Is(err, interfaces.ErrLastAdmin) {
+			return errors.New("cannot remove the last active admin")
+		}
+		return err
+	}
+
+	// Copies that were reserved for the user go to the next person in line
+	for _, bookID := range releasedBooks {
+		if err := s.holdService.PromoteWaiting(bookID); err != nil {
+			log.Printf("Failed to promote holds on book %d after deleting user %d: %v", bookID, userID, err)
+		}
+	}
+
+	// Tokens and API keys of deleted users stop working right away
+	s.userService.cache.invalidate(userID)
+	return s.sessionService.RevokeAllForUser(userID)
+}
+
+// AnonymizeDeleted erases the personal data of users deleted longer than the grace period ago
+func (s *AccountService) AnonymizeDeleted() error {
+	cutoff := time.Now().Add(-s.gracePeriod)
+
+	for {
+		ids, err := s.userRepo.GetDeletedBefore(cutoff, anonymizeBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			if err := s.userRepo.Anonymize(id); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			log.Printf("Anonymized deleted user %d", id)
+		}
+
+		if len(ids) < anonymizeBatchSize {
+			return nil
+		}
+	}
+}
+
+// StartAnonymizer runs AnonymizeDeleted on an interval in the background.
+// Call the returned function to stop it.
+func (s *AccountService) StartAnonymizer(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.AnonymizeDeleted(); err != nil {
+					log.Printf("Failed to anonymize deleted users: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
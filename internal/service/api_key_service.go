@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// apiKeyPrefix marks our keys, so they're easy to recognize in logs and secret scanners
+	apiKeyPrefix = "bk_"
+	// apiKeyVisibleLength is how much of a key is stored in clear to tell keys apart
+	apiKeyVisibleLength = len(apiKeyPrefix) + 8
+	// apiKeyTouchInterval limits how often last-used timestamps are written
+	apiKeyTouchInterval = time.Minute
+)
+
+// APIKeyService handles API keys for machine clients
+type APIKeyService struct {
+	apiKeyRepo  interfaces.APIKeyRepository
+	userService *UserService
+	roleService *RoleService
+	defaultTTL  time.Duration
+	maxTTL      time.Duration
+}
+
+// NewAPIKeyService creates a new API key service.
+// Keys expire after defaultTTL unless created with an earlier or later expiry, up to maxTTL.
+func NewAPIKeyService(apiKeyRepo interfaces.APIKeyRepository, userService *UserService, roleService *RoleService, defaultTTL, maxTTL time.Duration) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo:  apiKeyRepo,
+		userService: userService,
+		roleService: roleService,
+		defaultTTL:  defaultTTL,
+		maxTTL:      maxTTL,
+	}
+}
+
+// CreateKey creates an API key for a user and returns it in clear, the only time it is available.
+// Every permission must be granted by the user's role and covered by the scopes of the
+// caller's token, so a key never outranks the session that created it.
+func (s *APIKeyService) CreateKey(userID uint, callerScopes []models.Scope, name string, permissionNames []string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, errors.New("name is required")
+	}
+	if len(permissionNames) == 0 {
+		return "", nil, errors.New("at least one permission is required")
+	}
+
+	now := time.Now()
+	expiry := now.Add(s.defaultTTL)
+	if expiresAt != nil {
+		expiry = *expiresAt
+	}
+	if !expiry.After(now) {
+		return "", nil, errors.New("expiry must be in the future")
+	}
+	if expiry.After(now.Add(s.maxTTL)) {
+		return "", nil, errors.New("expiry is too far in the future")
+	}
+
+	user, err := s.userService.GetUserByID(userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, permission := range permissionNames {
+		allowed, err := s.roleService.HasPermission(user.Role, permission)
+		if err != nil {
+			return "", nil, err
+		}
+		scope, _ := models.ScopeForPermission(permission)
+		if !allowed || !models.HasScope(callerScopes, scope) {
+			return "", nil, errors.New("permission not granted to you: " + permission)
+		}
+	}
+
+	permissions, err := s.roleService.resolvePermissions(permissionNames)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, _, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	key := apiKeyPrefix + token
+
+	apiKey := &models.APIKey{
+		UserID:      user.ID,
+		Name:        truncate(name, 100),
+		Prefix:      key[:apiKeyVisibleLength],
+		KeyHash:     utils.HashToken(key),
+		Permissions: permissions,
+		ExpiresAt:   expiry,
+	}
+	if err := s.apiKeyRepo.Create(apiKey); err != nil {
+		return "", nil, err
+	}
+
+	return key, apiKey, nil
+}
+
+// ListKeys returns the unrevoked API keys of a user
+func (s *APIKeyService) ListKeys(userID uint) ([]models.APIKey, error) {
+	return s.apiKeyRepo.GetByUser(userID)
+}
+
+// RevokeKey revokes one of the user's own API keys
+func (s *APIKeyService) RevokeKey(userID, keyID uint) error {
+	revoked, err := s.apiKeyRepo.Revoke(keyID, userID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return errors.New("API key not found")
+	}
+	return nil
+}
+
+// RevokeAllForUser revokes every API key of a user, e.g. when an admin ends all their access
+func (s *APIKeyService) RevokeAllForUser(userID uint) error {
+	return s.apiKeyRepo.RevokeAllForUser(userID)
+}
+
+// AuthenticateAPIKey resolves an API key to what it may currently do. The key's
+// permissions are narrowed to those the owner's role still grants, so demoting
+// the owner also demotes their keys.
+func (s *APIKeyService) AuthenticateAPIKey(key string) (*models.APIKeyGrant, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, errors.New("invalid API key")
+	}
+
+	apiKey, err := s.apiKeyRepo.GetByHash(utils.HashToken(key))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid API key")
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !apiKey.IsUsable(now) {
+		return nil, errors.New("invalid API key")
+	}
+
+	user, err := s.userService.currentUser(apiKey.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.IsSuspended() {
+		return nil, errors.New("invalid API key")
+	}
+
+	permissions := make([]string, 0, len(apiKey.Permissions))
+	for _, name := range apiKey.PermissionNames() {
+		allowed, err := s.roleService.HasPermission(user.Role, name)
+		if err != nil {
+			return nil, err
+		}
+		if allowed {
+			permissions = append(permissions, name)
+		}
+	}
+
+	scopes := models.ScopesForPermissions(permissions)
+	if s.userService.emailVerification == EmailVerificationWrite && !user.IsEmailVerified() {
+		scopes = models.ReadOnlyScopes(scopes)
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.apiKeyRepo.TouchLastUsed(apiKey.ID, now); err != nil {
+			log.Printf("Failed to record use of API key %d: %v", apiKey.ID, err)
+		}
+	}
+
+	return &models.APIKeyGrant{
+		Key:         apiKey,
+		User:        user,
+		Permissions: permissions,
+		Scopes:      scopes,
+	}, nil
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"log"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+)
+
+// AuditService writes the audit log
+type AuditService struct {
+	auditRepo interfaces.AuditEventRepository
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(auditRepo interfaces.AuditEventRepository) *AuditService {
+	return &AuditService{auditRepo: auditRepo}
+}
+
+// Record appends an event to the audit log, implementing middleware.Auditor. A failure is logged rather than
+// returned, since the action being audited has already happened.
+func (s *AuditService) Record(event *models.AuditEvent) {
+	event.Path = truncate(event.Path, 255)
+	event.UserAgent = truncate(event.UserAgent, 255)
+	event.IPAddress = truncate(event.IPAddress, 45)
+
+	if err := s.auditRepo.Create(event); err != nil {
+		log.Printf("Failed to record audit event %s for user %d: %v", event.Action, event.UserID, err)
+	}
+}
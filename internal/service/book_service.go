@@ -2,6 +2,8 @@ package service
 
 import (
 	"errors"
+	"fmt"
+
 	"example/go_api_tutorial/internal/models"
 	"example/go_api_tutorial/internal/repository/interfaces"
 	"gorm.io/gorm"
@@ -9,18 +11,40 @@ import (
 
 // BookService handles business logic for books
 type BookService struct {
-	bookRepo interfaces.BookRepository
+	bookRepo    interfaces.BookRepository
+	holdService *HoldService
 }
 
 // NewBookService creates a new book service
-func NewBookService(bookRepo interfaces.BookRepository) *BookService {
+func NewBookService(bookRepo interfaces.BookRepository, holdService *HoldService) *BookService {
 	return &BookService{
-		bookRepo: bookRepo,
+		bookRepo:    bookRepo,
+		holdService: holdService,
 	}
 }
 
 // CreateBook creates a new book with validation
 func (s *BookService) CreateBook(book *models.Book) error {
+	if err := prepareNewBook(book); err != nil {
+		return err
+	}
+	
+	return s.bookRepo.Create(book)
+}
+
+// ImportBooks creates several books, all of them or none if any is invalid or fails
+func (s *BookService) ImportBooks(books []models.Book) error {
+	for i := range books {
+		if err := prepareNewBook(&books[i]); err != nil {
+			return fmt.Errorf("book %d: %w", i+1, err)
+		}
+	}
+	
+	return s.bookRepo.CreateAll(books)
+}
+
+// prepareNewBook validates a book about to be created
+func prepareNewBook(book *models.Book) error {
 	// Business logic validation
 	if book.Title == "" {
 		return errors.New("book title is required")
@@ -32,7 +56,9 @@ func (s *BookService) CreateBook(book *models.Book) error {
 		return errors.New("book quantity cannot be negative")
 	}
 	
-	return s.bookRepo.Create(book)
+	// A new book has every copy on the shelf
+	book.Available = book.Quantity
+	return nil
 }
 
 // GetAllBooks returns all books
@@ -74,17 +100,23 @@ func (s *BookService) UpdateBook(id uint, updatedBook *models.Book) (*models.Boo
 		return nil, errors.New("book quantity cannot be negative")
 	}
 	
+	// Stock changes go through the loan-aware quantity update
+	if updatedBook.Quantity != existingBook.Quantity {
+		if err := s.setQuantity(existingBook, updatedBook.Quantity); err != nil {
+			return nil, err
+		}
+	}
+	
 	// Update fields
 	existingBook.Title = updatedBook.Title
 	existingBook.Author = updatedBook.Author
-	existingBook.Quantity = updatedBook.Quantity
 	
 	err = s.bookRepo.Update(existingBook)
 	if err != nil {
 		return nil, err
 	}
 	
-	return existingBook, nil
+	return s.bookRepo.GetByID(id)
 }
 
 // DeleteBook deletes a book
@@ -122,14 +154,15 @@ func (s *BookService) GetBooksPaginated(page, pageSize int) ([]models.Book, int6
 	return s.bookRepo.GetPaginated(offset, pageSize)
 }
 
-// UpdateBookQuantity updates only the quantity of a book
+// UpdateBookQuantity updates the total number of copies of a book.
+// Copies currently out on loan stay unavailable, so the quantity cannot drop below them.
 func (s *BookService) UpdateBookQuantity(id uint, quantity int) error {
 	if quantity < 0 {
 		return errors.New("quantity cannot be negative")
 	}
 	
 	// Check if book exists
-	_, err := s.bookRepo.GetByID(id)
+	book, err := s.bookRepo.GetByID(id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("book not found")
@@ -137,5 +170,26 @@ func (s *BookService) UpdateBookQuantity(id uint, quantity int) error {
 		return err
 	}
 	
-	return s.bookRepo.UpdateQuantity(id, quantity)
+	return s.setQuantity(book, quantity)
+}
+
+// setQuantity validates a new total against the copies on loan and stores it
+func (s *BookService) setQuantity(book *models.Book, quantity int) error {
+	if quantity < book.OnLoan() {
+		return fmt.Errorf("quantity cannot be less than the %d copies currently on loan or awaiting pickup", book.OnLoan())
+	}
+	
+	if err := s.bookRepo.UpdateQuantity(book.ID, quantity); err != nil {
+		if errors.Is(err, interfaces.ErrQuantityBelowOnLoan) {
+			// A checkout landed between the read and the update
+			return errors.New("quantity cannot be less than the copies currently on loan or awaiting pickup")
+		}
+		return err
+	}
+	
+	// New copies go to the hold queue first
+	if quantity > book.Quantity {
+		return s.holdService.PromoteWaiting(book.ID)
+	}
+	return nil
 }
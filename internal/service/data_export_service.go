@@ -0,0 +1,241 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// exportBuildTimeout is how long an export may stay pending before it is
+// considered interrupted, for example by a restart, and cleaned up
+const exportBuildTimeout = time.Hour
+
+// DataExportService builds archives of everything stored about a user
+type DataExportService struct {
+	exportRepo  interfaces.DataExportRepository
+	userRepo    interfaces.UserRepository
+	loanRepo    interfaces.LoanRepository
+	holdRepo    interfaces.HoldRepository
+	sessionRepo interfaces.SessionRepository
+	auditRepo   interfaces.AuditEventRepository
+	dir         string
+	ttl         time.Duration
+	cooldown    time.Duration
+}
+
+// NewDataExportService creates a new data export service.
+// Archives are written to dir and can be downloaded for ttl after they are built;
+// a user may request one export per cooldown.
+func NewDataExportService(exportRepo interfaces.DataExportRepository, userRepo interfaces.UserRepository, loanRepo interfaces.LoanRepository, holdRepo interfaces.HoldRepository, sessionRepo interfaces.SessionRepository, auditRepo interfaces.AuditEventRepository, dir string, ttl, cooldown time.Duration) *DataExportService {
+	return &DataExportService{
+		exportRepo:  exportRepo,
+		userRepo:    userRepo,
+		loanRepo:    loanRepo,
+		holdRepo:    holdRepo,
+		sessionRepo: sessionRepo,
+		auditRepo:   auditRepo,
+		dir:         dir,
+		ttl:         ttl,
+		cooldown:    cooldown,
+	}
+}
+
+// RequestExport starts building an archive of the user's data in the background.
+// While one is being built, it is returned instead of starting another. Another export
+// within the cooldown of the last one that didn't fail is refused with a ThrottledError.
+func (s *DataExportService) RequestExport(userID uint) (*models.DataExport, error) {
+	pending, err := s.exportRepo.GetPendingByUser(userID)
+	if err == nil {
+		return pending, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	latest, err := s.exportRepo.GetLatestByUser(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		if wait := time.Until(latest.CreatedAt.Add(s.cooldown)); wait > 0 {
+			return nil, &ThrottledError{Message: "a data export was requested recently, try again later", RetryAfter: wait}
+		}
+	}
+
+	export := &models.DataExport{
+		UserID: userID,
+		Status: models.DataExportPending,
+	}
+	if err := s.exportRepo.Create(export); err != nil {
+		// Another request started one since the checks above
+		if errors.Is(err, interfaces.ErrPendingExportExists) {
+			return s.exportRepo.GetPendingByUser(userID)
+		}
+		return nil, err
+	}
+
+	go s.build(export.ID, userID)
+
+	return export, nil
+}
+
+// GetExport returns one of the user's exports. Other users' exports are reported as not found.
+func (s *DataExportService) GetExport(id, userID uint) (*models.DataExport, error) {
+	export, err := s.exportRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("export not found")
+		}
+		return nil, err
+	}
+
+	if export.UserID != userID {
+		return nil, errors.New("export not found")
+	}
+	if export.ExpiresAt != nil && !time.Now().Before(*export.ExpiresAt) {
+		return nil, errors.New("export has expired")
+	}
+	return export, nil
+}
+
+// build writes the archive and records the outcome
+func (s *DataExportService) build(exportID, userID uint) {
+	path, err := s.writeArchive(exportID, userID)
+	now := time.Now()
+	if err != nil {
+		log.Printf("Failed to build data export %d for user %d: %v", exportID, userID, err)
+		if err := s.exportRepo.MarkFailed(exportID, now, now.Add(s.ttl)); err != nil {
+			log.Printf("Failed to mark data export %d failed: %v", exportID, err)
+		}
+		return
+	}
+
+	if err := s.exportRepo.MarkReady(exportID, path, now, now.Add(s.ttl)); err != nil {
+		log.Printf("Failed to mark data export %d ready: %v", exportID, err)
+		os.Remove(path)
+	}
+}
+
+// writeArchive collects the user's data and writes it to a ZIP file of one JSON document per kind
+func (s *DataExportService) writeArchive(exportID, userID uint) (string, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return "", err
+	}
+	loans, err := s.loanRepo.GetByUser(userID, false)
+	if err != nil {
+		return "", err
+	}
+	holds, err := s.holdRepo.GetByUser(userID)
+	if err != nil {
+		return "", err
+	}
+	sessions, err := s.sessionRepo.GetByUser(userID)
+	if err != nil {
+		return "", err
+	}
+	events, err := s.auditRepo.GetByUser(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp(s.dir, fmt.Sprintf("export-%d-*.zip", exportID))
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+
+	archive := zip.NewWriter(file)
+	documents := []struct {
+		name string
+		data interface{}
+	}{
+		{"user.json", user},
+		{"loans.json", loans},
+		{"holds.json", holds},
+		{"sessions.json", sessions},
+		{"audit_events.json", events},
+	}
+	for _, document := range documents {
+		if err = writeJSONEntry(archive, document.name, document.data); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// writeJSONEntry adds an indented JSON document to a ZIP archive
+func writeJSONEntry(archive *zip.Writer, name string, data interface{}) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// Cleanup deletes expired exports and their files, and those whose build was interrupted
+func (s *DataExportService) Cleanup() error {
+	now := time.Now()
+	exports, err := s.exportRepo.GetExpired(now, now.Add(-exportBuildTimeout))
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if export.FilePath != "" {
+			if err := os.Remove(export.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := s.exportRepo.Delete(export.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartCleanup runs Cleanup on an interval in the background.
+// Call the returned function to stop it.
+func (s *DataExportService) StartCleanup(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Cleanup(); err != nil {
+					log.Printf("Failed to clean up data exports: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"example/go_api_tutorial/internal/mailer"
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/utils"
+	"gorm.io/gorm"
+)
+
+// emailVerification is the purpose of the tokens in verification links
+const emailVerification = "verify_email"
+
+// EmailVerificationService emails users a signed link confirming their address
+type EmailVerificationService struct {
+	userRepo    interfaces.UserRepository
+	userService *UserService
+	mailer      mailer.Mailer
+	jwtManager  *utils.JWTManager
+	baseURL     string
+	tokenTTL    time.Duration
+}
+
+// NewEmailVerificationService creates a new email verification service.
+// baseURL is where the API is reachable from the user's browser; links stay valid for tokenTTL.
+func NewEmailVerificationService(userRepo interfaces.UserRepository, userService *UserService, mailer mailer.Mailer, jwtManager *utils.JWTManager, baseURL string, tokenTTL time.Duration) *EmailVerificationService {
+	return &EmailVerificationService{
+		userRepo:    userRepo,
+		userService: userService,
+		mailer:      mailer,
+		jwtManager:  jwtManager,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		tokenTTL:    tokenTTL,
+	}
+}
+
+// SendVerification emails the user a link confirming their current address.
+// The link's token is signed for that address, so it stops working if the email changes.
+func (s *EmailVerificationService) SendVerification(user *models.User) error {
+	token, err := s.jwtManager.GenerateChallengeToken(user, emailVerification, s.tokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.baseURL + "/auth/verify-email?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening this link:\n\n%s\n\n"+
+			"The link expires in %s. If you didn't create an account, you can ignore this email.\n",
+			user.Username, link, s.tokenTTL),
+	})
+}
+
+// NotifyEmailChanged tells the user at their previous address that it was replaced,
+// so someone who took over the account can't quietly cut the owner off
+func (s *EmailVerificationService) NotifyEmailChanged(user *models.User, previousEmail string) error {
+	return s.mailer.Send(mailer.Message{
+		To:      previousEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\nThe email address of your account was changed from %s to %s.\n\n"+
+			"If you didn't make this change, contact us right away; password reset links now go to the new address.\n",
+			user.Username, previousEmail, user.Email),
+	})
+}
+
+// Verify confirms the address a verification token was issued for
+func (s *EmailVerificationService) Verify(token string) error {
+	claims, err := s.jwtManager.ValidateChallengeToken(token, emailVerification)
+	if err != nil {
+		return errors.New("invalid or expired verification link")
+	}
+	return s.userService.VerifyEmail(claims.UserID, claims.Email)
+}
+
+// Resend emails a new verification link to an unverified address. It does nothing for
+// unknown or verified addresses, so callers can't tell which addresses have accounts.
+func (s *EmailVerificationService) Resend(email string) error {
+	user, err := s.userRepo.GetByEmail(strings.TrimSpace(strings.ToLower(email)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.IsEmailVerified() {
+		return nil
+	}
+	return s.SendVerification(user)
+}
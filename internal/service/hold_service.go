@@ -0,0 +1,213 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// HoldService handles business logic for the hold (waitlist) queue
+type HoldService struct {
+	holdRepo     interfaces.HoldRepository
+	bookRepo     interfaces.BookRepository
+	pickupWindow time.Duration
+}
+
+// NewHoldService creates a new hold service
+func NewHoldService(holdRepo interfaces.HoldRepository, bookRepo interfaces.BookRepository, pickupWindow time.Duration) *HoldService {
+	return &HoldService{
+		holdRepo:     holdRepo,
+		bookRepo:     bookRepo,
+		pickupWindow: pickupWindow,
+	}
+}
+
+// PlaceHold adds a user to the end of a book's waitlist
+func (s *HoldService) PlaceHold(userID, bookID uint) (*models.Hold, error) {
+	book, err := s.bookRepo.GetByID(bookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("book not found")
+		}
+		return nil, err
+	}
+
+	if book.Available > 0 {
+		return nil, errors.New("copies are available, check out the book instead")
+	}
+
+	_, err = s.holdRepo.GetActiveByUserAndBook(userID, bookID)
+	if err == nil {
+		return nil, errors.New("hold already exists for this book")
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hold := &models.Hold{
+		UserID: userID,
+		BookID: bookID,
+		Status: models.HoldWaiting,
+	}
+	if err := s.holdRepo.Create(hold); err != nil {
+		// Another request placed the same hold since the check above
+		if errors.Is(err, interfaces.ErrActiveHoldExists) {
+			return nil, errors.New("hold already exists for this book")
+		}
+		return nil, err
+	}
+
+	// A copy may have come back between the availability check and the insert
+	if err := s.PromoteWaiting(bookID); err != nil {
+		return nil, err
+	}
+
+	return s.withPosition(hold.ID)
+}
+
+// GetUserHolds returns a user's active holds with their queue positions
+func (s *HoldService) GetUserHolds(userID uint) ([]models.Hold, error) {
+	holds, err := s.holdRepo.GetActiveByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range holds {
+		if holds[i].Status != models.HoldWaiting {
+			continue
+		}
+		position, err := s.holdRepo.QueuePosition(&holds[i])
+		if err != nil {
+			return nil, err
+		}
+		holds[i].Position = int(position)
+	}
+
+	return holds, nil
+}
+
+// CancelHold cancels a hold. Only the holder or a user allowed to manage loans may cancel it.
+func (s *HoldService) CancelHold(holdID, userID uint, canManage bool) error {
+	hold, err := s.holdRepo.GetByID(holdID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("hold not found")
+		}
+		return err
+	}
+
+	// Don't reveal other users' holds
+	if hold.UserID != userID && !canManage {
+		return errors.New("hold not found")
+	}
+
+	released, err := s.holdRepo.Cancel(hold)
+	if err != nil {
+		if errors.Is(err, interfaces.ErrHoldNotActive) {
+			return errors.New("hold is no longer active")
+		}
+		return err
+	}
+
+	// The copy reserved for this hold goes to the next person in line
+	if released {
+		return s.PromoteWaiting(hold.BookID)
+	}
+	return nil
+}
+
+// PromoteWaiting reserves available copies of a book for the oldest waiting holds.
+// It is called whenever copies come back into circulation.
+func (s *HoldService) PromoteWaiting(bookID uint) error {
+	for {
+		hold, err := s.holdRepo.PromoteNext(bookID, time.Now().Add(s.pickupWindow))
+		if err != nil {
+			return err
+		}
+		if hold == nil {
+			return nil
+		}
+		log.Printf("Hold %d on book %d is ready for pickup by user %d", hold.ID, hold.BookID, hold.UserID)
+	}
+}
+
+// ExpireReadyHolds lapses ready holds past their pickup window and promotes the next in line
+func (s *HoldService) ExpireReadyHolds() error {
+	holds, err := s.holdRepo.GetExpiredReady(time.Now())
+	if err != nil {
+		return err
+	}
+
+	for i := range holds {
+		if err := s.holdRepo.Expire(&holds[i]); err != nil {
+			// Picked up or cancelled since we listed it
+			if errors.Is(err, interfaces.ErrHoldNotActive) {
+				continue
+			}
+			return err
+		}
+		if err := s.PromoteWaiting(holds[i].BookID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StartExpirer runs ExpireReadyHolds on an interval in the background.
+// Call the returned function to stop it.
+func (s *HoldService) StartExpirer(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.ExpireReadyHolds(); err != nil {
+					log.Printf("Failed to expire holds: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// readyHold returns the user's hold on a book if a copy is reserved for them
+func (s *HoldService) readyHold(userID, bookID uint) (*models.Hold, error) {
+	hold, err := s.holdRepo.GetActiveByUserAndBook(userID, bookID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if hold.Status != models.HoldReady {
+		return nil, nil
+	}
+	return hold, nil
+}
+
+// withPosition reloads a hold and fills in its queue position
+func (s *HoldService) withPosition(holdID uint) (*models.Hold, error) {
+	hold, err := s.holdRepo.GetByID(holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status == models.HoldWaiting {
+		position, err := s.holdRepo.QueuePosition(hold)
+		if err != nil {
+			return nil, err
+		}
+		hold.Position = int(position)
+	}
+	return hold, nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// fakeHoldRepository keeps holds in memory. Methods cancelling doesn't use are left
+// to the embedded interface and panic if called.
+type fakeHoldRepository struct {
+	interfaces.HoldRepository
+	holds map[uint]*models.Hold
+	// promoteBeforeCancel makes waiting holds ready between reading and cancelling them
+	promoteBeforeCancel bool
+	promotedBooks       []uint
+}
+
+func (r *fakeHoldRepository) GetByID(id uint) (*models.Hold, error) {
+	hold, ok := r.holds[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	read := *hold
+	return &read, nil
+}
+
+func (r *fakeHoldRepository) Cancel(hold *models.Hold) (bool, error) {
+	current := r.holds[hold.ID]
+	if r.promoteBeforeCancel && current.Status == models.HoldWaiting {
+		current.Status = models.HoldReady
+	}
+	if !current.IsActive() {
+		return false, interfaces.ErrHoldNotActive
+	}
+	released := current.Status == models.HoldReady
+	current.Status = models.HoldCancelled
+	hold.Status = models.HoldCancelled
+	return released, nil
+}
+
+func (r *fakeHoldRepository) PromoteNext(bookID uint, expiresAt time.Time) (*models.Hold, error) {
+	r.promotedBooks = append(r.promotedBooks, bookID)
+	return nil, nil
+}
+
+func TestCancelHoldPromotesNextWhenCopyReleased(t *testing.T) {
+	tests := []struct {
+		name                string
+		status              models.HoldStatus
+		promoteBeforeCancel bool
+		promotes            bool
+	}{
+		{"waiting", models.HoldWaiting, false, false},
+		{"ready", models.HoldReady, false, true},
+		// The copy released for a hold promoted after it was read must still go to the next in line
+		{"promoted while cancelling", models.HoldWaiting, true, true},
+	}
+	for _, tt := range tests {
+		repo := &fakeHoldRepository{
+			holds:               map[uint]*models.Hold{1: {ID: 1, BookID: 7, UserID: 3, Status: tt.status}},
+			promoteBeforeCancel: tt.promoteBeforeCancel,
+		}
+		holds := NewHoldService(repo, nil, time.Hour)
+
+		if err := holds.CancelHold(1, 3, false); err != nil {
+			t.Fatalf("%s: CancelHold: %v", tt.name, err)
+		}
+		if promoted := len(repo.promotedBooks) > 0; promoted != tt.promotes {
+			t.Errorf("%s: promoted next hold = %v, want %v", tt.name, promoted, tt.promotes)
+		}
+		if tt.promotes && repo.promotedBooks[0] != 7 {
+			t.Errorf("%s: promoted holds on book %d, want 7", tt.name, repo.promotedBooks[0])
+		}
+	}
+}
+
+func TestCancelHoldOfAnotherUser(t *testing.T) {
+	repo := &fakeHoldRepository{holds: map[uint]*models.Hold{1: {ID: 1, BookID: 7, UserID: 3, Status: models.HoldReady}}}
+	holds := NewHoldService(repo, nil, time.Hour)
+
+	if err := holds.CancelHold(1, 4, false); err == nil || err.Error() != "hold not found" {
+		t.Errorf("CancelHold by another user: err = %v, want hold not found", err)
+	}
+	if repo.holds[1].Status != models.HoldReady {
+		t.Error("another user's hold was cancelled")
+	}
+
+	// Staff may cancel it
+	if err := holds.CancelHold(1, 4, true); err != nil {
+		t.Errorf("CancelHold by staff: %v", err)
+	}
+}
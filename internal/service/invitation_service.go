@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Registration modes: who may create an account through /auth/register
+const (
+	RegistrationOpen   = "open"   // anyone; an invitation is optional and can grant a role
+	RegistrationInvite = "invite" // only people with an invitation
+	RegistrationClosed = "closed" // nobody, accounts are created by other means
+)
+
+const (
+	// invitationPrefix marks our invitation codes
+	invitationPrefix = "inv_"
+	// invitationVisibleLength is how much of a code is stored in clear to tell invitations apart
+	invitationVisibleLength = len(invitationPrefix) + 8
+)
+
+// InvitationService handles registration and the invitations it may require
+type InvitationService struct {
+	invitationRepo interfaces.InvitationRepository
+	userService    *UserService
+	roleService    *RoleService
+	mode           string
+	defaultTTL     time.Duration
+}
+
+// NewInvitationService creates a new invitation service.
+// mode is one of RegistrationOpen, RegistrationInvite or RegistrationClosed;
+// invitations expire after defaultTTL unless created with another expiry.
+func NewInvitationService(invitationRepo interfaces.InvitationRepository, userService *UserService, roleService *RoleService, mode string, defaultTTL time.Duration) *InvitationService {
+	return &InvitationService{
+		invitationRepo: invitationRepo,
+		userService:    userService,
+		roleService:    roleService,
+		mode:           mode,
+		defaultTTL:     defaultTTL,
+	}
+}
+
+// CreateInvitation creates an invitation and returns its code in clear, the only time it is available.
+// An empty role means the default user role; other roles need the creator to be allowed to
+// change roles. A non-empty email locks the invitation to that address.
+func (s *InvitationService) CreateInvitation(createdByID uint, role models.UserRole, email string, maxUses int, expiresAt *time.Time) (string, *models.Invitation, error) {
+	if role == "" {
+		role = models.RoleUser
+	}
+	exists, err := s.roleService.RoleExists(role)
+	if err != nil {
+		return "", nil, err
+	}
+	if !exists {
+		return "", nil, errors.New("invalid role")
+	}
+
+	// Inviting someone into a role amounts to assigning it
+	if role != models.RoleUser {
+		creator, err := s.userService.GetUserByID(createdByID)
+		if err != nil {
+			return "", nil, err
+		}
+		allowed, err := s.roleService.HasPermission(creator.Role, models.PermissionUserRoleUpdate)
+		if err != nil {
+			return "", nil, err
+		}
+		if !allowed {
+			return "", nil, errors.New("inviting to a role other than user requires " + models.PermissionUserRoleUpdate)
+		}
+	}
+
+	if maxUses == 0 {
+		maxUses = 1
+	}
+	if maxUses < 0 {
+		return "", nil, errors.New("max uses must be positive")
+	}
+
+	now := time.Now()
+	expiry := now.Add(s.defaultTTL)
+	if expiresAt != nil {
+		expiry = *expiresAt
+	}
+	if !expiry.After(now) {
+		return "", nil, errors.New("expiry must be in the future")
+	}
+
+	token, _, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	code := invitationPrefix + token
+
+	invitation := &models.Invitation{
+		Prefix:      code[:invitationVisibleLength],
+		CodeHash:    utils.HashToken(code),
+		Role:        role,
+		MaxUses:     maxUses,
+		ExpiresAt:   expiry,
+		CreatedByID: createdByID,
+	}
+	if email = strings.TrimSpace(strings.ToLower(email)); email != "" {
+		invitation.Email = &email
+	}
+	if err := s.invitationRepo.Create(invitation); err != nil {
+		return "", nil, err
+	}
+
+	return code, invitation, nil
+}
+
+// ListInvitations returns every invitation, including used up, expired and revoked ones
+func (s *InvitationService) ListInvitations() ([]models.Invitation, error) {
+	return s.invitationRepo.GetAll()
+}
+
+// RevokeInvitation stops an invitation from being used
+func (s *InvitationService) RevokeInvitation(id uint) error {
+	revoked, err := s.invitationRepo.Revoke(id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return errors.New("invitation not found")
+	}
+	return nil
+}
+
+// Register creates an account according to the registration mode. With an invitation
+// code, the user gets the invitation's role and the invitation is used up by one.
+func (s *InvitationService) Register(username, email, password, inviteCode string) (*models.User, error) {
+	inviteCode = strings.TrimSpace(inviteCode)
+
+	switch {
+	case s.mode == RegistrationClosed:
+		return nil, errors.New("registration is closed")
+	case inviteCode == "" && s.mode == RegistrationInvite:
+		return nil, errors.New("an invitation is required to register")
+	case inviteCode == "":
+		return s.userService.RegisterUser(username, email, password)
+	}
+
+	invitation, err := s.invitationRepo.GetByCodeHash(utils.HashToken(inviteCode))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired invitation")
+		}
+		return nil, err
+	}
+	if !invitation.IsUsable(time.Now()) {
+		return nil, errors.New("invalid or expired invitation")
+	}
+	if invitation.Email != nil && !strings.EqualFold(*invitation.Email, strings.TrimSpace(email)) {
+		return nil, errors.New("invitation is for another email address")
+	}
+
+	user, err := s.userService.newUser(username, email, password)
+	if err != nil {
+		return nil, err
+	}
+	user.Role = invitation.Role
+
+	if err := s.invitationRepo.Redeem(invitation.ID, user); err != nil {
+		if errors.Is(err, interfaces.ErrInvitationNotUsable) {
+			return nil, errors.New("invalid or expired invitation")
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// LoanService handles business logic for book loans
+type LoanService struct {
+	loanRepo    interfaces.LoanRepository
+	bookRepo    interfaces.BookRepository
+	holdService *HoldService
+	loanPeriod  time.Duration
+}
+
+// NewLoanService creates a new loan service
+func NewLoanService(loanRepo interfaces.LoanRepository, bookRepo interfaces.BookRepository, holdService *HoldService, loanPeriod time.Duration) *LoanService {
+	return &LoanService{
+		loanRepo:    loanRepo,
+		bookRepo:    bookRepo,
+		holdService: holdService,
+		loanPeriod:  loanPeriod,
+	}
+}
+
+// CheckoutBook lends one copy of a book to a user, using the copy reserved
+// for them if their hold is ready for pickup
+func (s *LoanService) CheckoutBook(userID, bookID uint) (*models.Loan, error) {
+	// Check if book exists
+	if _, err := s.bookRepo.GetByID(bookID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("book not found")
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	loan := &models.Loan{
+		UserID:       userID,
+		BookID:       bookID,
+		CheckedOutAt: now,
+		DueAt:        now.Add(s.loanPeriod),
+	}
+
+	hold, err := s.holdService.readyHold(userID, bookID)
+	if err != nil {
+		return nil, err
+	}
+	if hold != nil {
+		err = s.loanRepo.CheckoutHold(loan, hold.ID)
+		if err == nil {
+			return loan, nil
+		}
+		// The hold lapsed in the meantime; fall back to a regular checkout
+		if !errors.Is(err, interfaces.ErrHoldNotActive) {
+			return nil, err
+		}
+	}
+
+	if err := s.loanRepo.Checkout(loan); err != nil {
+		if errors.Is(err, interfaces.ErrNoCopiesAvailable) {
+			return nil, errors.New("no copies available")
+		}
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+// ReturnLoan returns a borrowed copy. Only the borrower or a user allowed to manage loans may return it.
+func (s *LoanService) ReturnLoan(loanID, userID uint, canManage bool) (*models.Loan, error) {
+	loan, err := s.loanRepo.GetByID(loanID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("loan not found")
+		}
+		return nil, err
+	}
+
+	// Don't reveal other users' loans
+	if loan.UserID != userID && !canManage {
+		return nil, errors.New("loan not found")
+	}
+
+	if err := s.loanRepo.Return(loan, time.Now()); err != nil {
+		if errors.Is(err, interfaces.ErrLoanAlreadyReturned) {
+			return nil, errors.New("loan already returned")
+		}
+		return nil, err
+	}
+
+	// The returned copy goes to the next person in the hold queue
+	if err := s.holdService.PromoteWaiting(loan.BookID); err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+// GetUserLoans returns a user's loans, optionally only those not yet returned
+func (s *LoanService) GetUserLoans(userID uint, activeOnly bool) ([]models.Loan, error) {
+	return s.loanRepo.GetByUser(userID, activeOnly)
+}
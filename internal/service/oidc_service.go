@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/oidc"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/utils"
+	"gorm.io/gorm"
+)
+
+// usernameUnsafeChars matches what is dropped from provider names to make a username
+var usernameUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// providerMFAMethods are amr values (RFC 8176) showing the provider checked a second factor
+var providerMFAMethods = []string{"mfa", "otp", "hwk", "swk", "sms"}
+
+// GroupRole maps an identity provider group to the role its members get
+type GroupRole struct {
+	Group string
+	Role  models.UserRole
+}
+
+// OIDCService signs users in through an external OpenID Connect provider
+type OIDCService struct {
+	provider    *oidc.Provider
+	loginRepo   interfaces.OIDCLoginRepository
+	userRepo    interfaces.UserRepository
+	userService *UserService
+	groupRoles  []GroupRole
+	defaultRole models.UserRole
+	loginTTL    time.Duration
+	autoCreate  bool
+}
+
+// NewOIDCService creates a new OIDC service.
+// groupRoles are tried in order and the first group the user is in decides their role;
+// users in none of them get defaultRole. Without groupRoles, roles are managed locally
+// and new users get defaultRole. loginTTL is how long the user has to sign in at the provider.
+func NewOIDCService(provider *oidc.Provider, loginRepo interfaces.OIDCLoginRepository, userRepo interfaces.UserRepository, userService *UserService, groupRoles []GroupRole, defaultRole models.UserRole, loginTTL time.Duration) *OIDCService {
+	return &OIDCService{
+		provider:    provider,
+		loginRepo:   loginRepo,
+		userRepo:    userRepo,
+		userService: userService,
+		groupRoles:  groupRoles,
+		defaultRole: defaultRole,
+		loginTTL:    loginTTL,
+		autoCreate:  true,
+	}
+}
+
+// WithProvisioning sets whether sign-ins of provider accounts matching no user create
+// one. Without it, only existing users can sign in through the provider.
+func (s *OIDCService) WithProvisioning(provision bool) *OIDCService {
+	s.autoCreate = provision
+	return s
+}
+
+// LoginTTL returns how long a sign-in started at the provider can be completed
+func (s *OIDCService) LoginTTL() time.Duration {
+	return s.loginTTL
+}
+
+// StartLogin begins a sign-in and returns the provider URL to redirect to and the state
+// the callback must present. The caller binds the state to the browser, e.g. in a cookie.
+func (s *OIDCService) StartLogin(ctx context.Context) (string, string, error) {
+	state, err := utils.RandomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.RandomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.loginRepo.DeleteExpired(time.Now()); err != nil {
+		return "", "", err
+	}
+	login := &models.OIDCLogin{
+		StateHash:    utils.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(s.loginTTL),
+	}
+	if err := s.loginRepo.Create(login); err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// CompleteLogin finishes a sign-in with the code the provider redirected back with.
+// browserState is the state bound to the browser that started the sign-in. It returns
+// the signed-in user, provisioned or linked by verified email address on first sign-in,
+// and whether the provider checked a second factor.
+func (s *OIDCService) CompleteLogin(ctx context.Context, state, browserState, code string) (*models.User, bool, error) {
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, false, errors.New("invalid or expired sign-in")
+	}
+
+	login, err := s.loginRepo.Consume(utils.HashToken(state))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, errors.New("invalid or expired sign-in")
+		}
+		return nil, false, err
+	}
+	if !time.Now().Before(login.ExpiresAt) {
+		return nil, false, errors.New("invalid or expired sign-in")
+	}
+
+	idToken, err := s.provider.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Printf("OIDC sign-in failed: %v", err)
+		return nil, false, errors.New("identity provider sign-in failed")
+	}
+
+	user, err := s.resolveUser(idToken)
+	if err != nil {
+		return nil, false, err
+	}
+	if user.IsSuspended() {
+		return nil, false, errors.New("account is suspended")
+	}
+
+	mfa := false
+	for _, method := range idToken.AMR {
+		for _, m := range providerMFAMethods {
+			if method == m {
+				mfa = true
+			}
+		}
+	}
+
+	user.Password = ""
+	user.TOTPSecret = ""
+	return user, mfa, nil
+}
+
+// resolveUser finds the user linked to the provider account, links the user with the
+// token's verified email address, or creates a new user, then syncs the role
+func (s *OIDCService) resolveUser(idToken *oidc.IDToken) (*models.User, error) {
+	role, mapped := s.roleForGroups(idToken.Groups)
+
+	user, err := s.userRepo.GetByOIDCIdentity(idToken.Issuer, idToken.Subject)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if user == nil {
+		email := strings.TrimSpace(strings.ToLower(idToken.Email))
+		if email == "" || !idToken.EmailVerified {
+			return nil, errors.New("identity provider did not confirm an email address")
+		}
+
+		user, err = s.userRepo.GetByEmail(email)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		if user == nil {
+			if !s.autoCreate {
+				return nil, errors.New("no account found for this identity")
+			}
+			return s.provision(idToken, email, role)
+		}
+
+		linked, err := s.userRepo.LinkOIDCIdentity(user.ID, idToken.Issuer, idToken.Subject)
+		if err != nil {
+			return nil, err
+		}
+		if !linked {
+			return nil, errors.New("account is linked to another identity")
+		}
+		log.Printf("Linked user %d to identity provider account %s", user.ID, idToken.Subject)
+
+		// The provider vouches for the address
+		if !user.IsEmailVerified() {
+			if _, err := s.userRepo.MarkEmailVerified(user.ID, user.Email); err != nil {
+				return nil, err
+			}
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+	}
+
+	if mapped && user.Role != role {
+		err := s.userService.UpdateUserRole(user.ID, role)
+		switch {
+		case err != nil && err.Error() == "cannot remove the last active admin":
+			// Keep the role rather than lock everyone out of admin routes
+			log.Printf("Kept admin role of user %d, the last active admin, despite identity provider groups", user.ID)
+		case err != nil:
+			return nil, err
+		default:
+			log.Printf("Changed role of user %d from %s to %s after identity provider sign-in", user.ID, user.Role, role)
+			user.Role = role
+		}
+	}
+	return user, nil
+}
+
+// provision creates a user for a provider account. The user has no password and
+// can only sign in through the provider, unless they set one by resetting it.
+func (s *OIDCService) provision(idToken *oidc.IDToken, email string, role models.UserRole) (*models.User, error) {
+	username, err := s.availableUsername(idToken, email)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	issuer, subject := idToken.Issuer, idToken.Subject
+	user := &models.User{
+		Username:        username,
+		Email:           email,
+		EmailVerifiedAt: &now,
+		Role:            role,
+		OIDCIssuer:      &issuer,
+		OIDCSubject:     &subject,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Created user %d for identity provider account %s", user.ID, subject)
+	return user, nil
+}
+
+// availableUsername derives an unused username from the provider's username or the
+// email address, adding a random suffix when it's taken
+func (s *OIDCService) availableUsername(idToken *oidc.IDToken, email string) (string, error) {
+	base := usernameUnsafeChars.ReplaceAllString(idToken.PreferredUsername, "")
+	if len(base) < 3 {
+		base = usernameUnsafeChars.ReplaceAllString(strings.SplitN(email, "@", 2)[0], "")
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+	base = truncate(base, 40)
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		exists, err := s.userRepo.ExistsByUsername(candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+
+		suffix, err := utils.RandomHex(3)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "-" + suffix
+	}
+	return "", errors.New("could not find an available username")
+}
+
+// roleForGroups returns the role the provider groups map to, and whether roles are mapped at all
+func (s *OIDCService) roleForGroups(groups []string) (models.UserRole, bool) {
+	if len(s.groupRoles) == 0 {
+		return s.defaultRole, false
+	}
+	for _, mapping := range s.groupRoles {
+		for _, group := range groups {
+			if group == mapping.Group {
+				return mapping.Role, true
+			}
+		}
+	}
+	return s.defaultRole, true
+}
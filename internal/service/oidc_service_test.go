@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/oidc"
+	"example/go_api_tutorial/internal/oidc/oidctest"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/repository/memory"
+	"gorm.io/gorm"
+)
+
+// fakeUserRepository keeps users in memory. Methods the sign-in doesn't use are left
+// to the embedded interface and panic if called.
+type fakeUserRepository struct {
+	interfaces.UserRepository
+	users []*models.User
+}
+
+func (r *fakeUserRepository) Create(user *models.User) error {
+	user.ID = uint(len(r.users) + 1)
+	stored := *user
+	r.users = append(r.users, &stored)
+	return nil
+}
+
+func (r *fakeUserRepository) find(match func(*models.User) bool) (*models.User, error) {
+	for _, user := range r.users {
+		if match(user) {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeUserRepository) GetByID(id uint) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.ID == id })
+}
+
+func (r *fakeUserRepository) GetByEmail(email string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.Email == email })
+}
+
+func (r *fakeUserRepository) GetByOIDCIdentity(issuer, subject string) (*models.User, error) {
+	return r.find(func(u *models.User) bool {
+		return u.OIDCIssuer != nil && *u.OIDCIssuer == issuer && u.OIDCSubject != nil && *u.OIDCSubject == subject
+	})
+}
+
+func (r *fakeUserRepository) ExistsByUsername(username string) (bool, error) {
+	_, err := r.find(func(u *models.User) bool { return u.Username == username })
+	return err == nil, nil
+}
+
+func (r *fakeUserRepository) LinkOIDCIdentity(id uint, issuer, subject string) (bool, error) {
+	user := r.users[id-1]
+	if user.OIDCSubject != nil {
+		return false, nil
+	}
+	user.OIDCIssuer, user.OIDCSubject = &issuer, &subject
+	return true, nil
+}
+
+func (r *fakeUserRepository) MarkEmailVerified(id uint, email string) (bool, error) {
+	now := time.Now()
+	r.users[id-1].EmailVerifiedAt = &now
+	return true, nil
+}
+
+func (r *fakeUserRepository) UpdateRole(id uint, role models.UserRole) error {
+	r.users[id-1].Role = role
+	return nil
+}
+
+// fakeRoleRepository knows the built-in roles
+type fakeRoleRepository struct {
+	interfaces.RoleRepository
+}
+
+func (fakeRoleRepository) GetByName(name models.UserRole) (*models.Role, error) {
+	for _, role := range models.BuiltInRoles {
+		if role.Name == name {
+			return &role, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// fakeOIDCLoginRepository keeps pending sign-ins in memory
+type fakeOIDCLoginRepository struct {
+	logins map[string]*models.OIDCLogin
+}
+
+func (r *fakeOIDCLoginRepository) Create(login *models.OIDCLogin) error {
+	r.logins[login.StateHash] = login
+	return nil
+}
+
+func (r *fakeOIDCLoginRepository) Consume(stateHash string) (*models.OIDCLogin, error) {
+	login, ok := r.logins[stateHash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(r.logins, stateHash)
+	return login, nil
+}
+
+func (r *fakeOIDCLoginRepository) DeleteExpired(now time.Time) error {
+	for hash, login := range r.logins {
+		if !now.Before(login.ExpiresAt) {
+			delete(r.logins, hash)
+		}
+	}
+	return nil
+}
+
+// oidcTest is an OIDC service signing in at a mock provider
+type oidcTest struct {
+	t       *testing.T
+	server  *oidctest.Server
+	users   *fakeUserRepository
+	service *OIDCService
+}
+
+func newOIDCTest(t *testing.T, groupRoles []GroupRole) *oidcTest {
+	t.Helper()
+	server := oidctest.NewServer("library", "secret")
+	t.Cleanup(server.Close)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Issuer:       server.URL,
+		ClientID:     "library",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/api/v1/auth/oidc/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+		GroupsClaim:  "groups",
+	})
+	users := &fakeUserRepository{}
+	roleService := NewRoleService(fakeRoleRepository{}, time.Minute)
+	throttleService := NewThrottleService(memory.NewLoginAttemptStore(), LockoutPolicy{})
+	userService := NewUserService(users, roleService, throttleService, 0, time.Minute)
+	logins := &fakeOIDCLoginRepository{logins: make(map[string]*models.OIDCLogin)}
+
+	return &oidcTest{
+		t:       t,
+		server:  server,
+		users:   users,
+		service: NewOIDCService(provider, logins, users, userService, groupRoles, models.RoleUser, 10*time.Minute),
+	}
+}
+
+// signIn signs in at the provider with the given ID token claims
+func (o *oidcTest) signIn(claims map[string]interface{}) (*models.User, bool, error) {
+	o.t.Helper()
+	o.server.SetClaims(claims)
+	authURL, browserState, err := o.service.StartLogin(context.Background())
+	if err != nil {
+		o.t.Fatalf("StartLogin: %v", err)
+	}
+	code, state, err := o.server.Authorize(authURL)
+	if err != nil {
+		o.t.Fatalf("Authorize: %v", err)
+	}
+	return o.service.CompleteLogin(context.Background(), state, browserState, code)
+}
+
+func (o *oidcTest) addUser(user models.User) *models.User {
+	o.users.Create(&user)
+	return o.users.users[len(o.users.users)-1]
+}
+
+func TestOIDCLoginRejectsStateMismatch(t *testing.T) {
+	o := newOIDCTest(t, nil)
+	o.server.SetClaims(map[string]interface{}{"email": "alice@example.com", "email_verified": true})
+	authURL, browserState, err := o.service.StartLogin(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := o.server.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A callback started in another browser, or with a forged state
+	if _, _, err := o.service.CompleteLogin(context.Background(), state, "other-state", code); err == nil || err.Error() != "invalid or expired sign-in" {
+		t.Errorf("mismatched browser state: err = %v", err)
+	}
+	if _, _, err := o.service.CompleteLogin(context.Background(), "forged", "forged", code); err == nil || err.Error() != "invalid or expired sign-in" {
+		t.Errorf("unknown state: err = %v", err)
+	}
+
+	if _, _, err := o.service.CompleteLogin(context.Background(), state, browserState, code); err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	// Each sign-in completes once
+	if _, _, err := o.service.CompleteLogin(context.Background(), state, browserState, code); err == nil || err.Error() != "invalid or expired sign-in" {
+		t.Errorf("replayed state: err = %v", err)
+	}
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	o := newOIDCTest(t, nil)
+	o.addUser(models.User{Username: "alice", Email: "someone@example.com"})
+
+	user, mfa, err := o.signIn(map[string]interface{}{
+		"sub":                "alice-id",
+		"email":              "Alice@Example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"amr":                []string{"pwd", "otp"},
+	})
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if !mfa {
+		t.Error("second factor at the provider not recognized")
+	}
+	if user.Email != "alice@example.com" || !strings.HasPrefix(user.Username, "alice-") || user.Role != models.RoleUser {
+		t.Errorf("provisioned user %q <%s> with role %s", user.Username, user.Email, user.Role)
+	}
+	if user.OIDCIssuer == nil || *user.OIDCIssuer != o.server.URL || user.OIDCSubject == nil || *user.OIDCSubject != "alice-id" {
+		t.Error("provisioned user is not linked to the provider account")
+	}
+
+	// The next sign-in finds the same user by identity, whatever the email says now
+	again, _, err := o.signIn(map[string]interface{}{"sub": "alice-id", "email": "new@example.com"})
+	if err != nil {
+		t.Fatalf("second CompleteLogin: %v", err)
+	}
+	if again.ID != user.ID || len(o.users.users) != 2 {
+		t.Errorf("second sign-in gave user %d, want %d", again.ID, user.ID)
+	}
+}
+
+func TestOIDCLoginWithoutProvisioning(t *testing.T) {
+	o := newOIDCTest(t, nil)
+	o.service.WithProvisioning(false)
+
+	_, _, err := o.signIn(map[string]interface{}{"email": "alice@example.com", "email_verified": true})
+	if err == nil || err.Error() != "no account found for this identity" {
+		t.Errorf("err = %v", err)
+	}
+	if len(o.users.users) != 0 {
+		t.Error("user was created")
+	}
+}
+
+func TestOIDCLoginLinksAccountByVerifiedEmail(t *testing.T) {
+	o := newOIDCTest(t, nil)
+	existing := o.addUser(models.User{Username: "alice", Email: "alice@example.com", Password: "hash"})
+
+	// Without the provider vouching for the address, the account isn't taken over
+	_, _, err := o.signIn(map[string]interface{}{"sub": "alice-id", "email": "alice@example.com", "email_verified": false})
+	if err == nil || err.Error() != "identity provider did not confirm an email address" {
+		t.Errorf("unverified email: err = %v", err)
+	}
+	if existing.OIDCSubject != nil {
+		t.Fatal("account linked to an unverified email address")
+	}
+
+	user, _, err := o.signIn(map[string]interface{}{"sub": "alice-id", "email": "ALICE@example.com", "email_verified": "true"})
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if user.ID != existing.ID || user.Password != "" {
+		t.Errorf("signed in as user %d, want %d without credentials", user.ID, existing.ID)
+	}
+	if existing.OIDCSubject == nil || *existing.OIDCSubject != "alice-id" || *existing.OIDCIssuer != o.server.URL {
+		t.Error("account not linked")
+	}
+	if !existing.IsEmailVerified() {
+		t.Error("email not marked verified")
+	}
+
+	// Another provider account with the same address can't sign in as alice
+	_, _, err = o.signIn(map[string]interface{}{"sub": "mallory-id", "email": "alice@example.com", "email_verified": true})
+	if err == nil || err.Error() != "account is linked to another identity" {
+		t.Errorf("second identity: err = %v", err)
+	}
+}
+
+func TestOIDCLoginRejectsSuspendedUser(t *testing.T) {
+	o := newOIDCTest(t, nil)
+	now := time.Now()
+	o.addUser(models.User{Username: "alice", Email: "alice@example.com", SuspendedAt: &now})
+
+	_, _, err := o.signIn(map[string]interface{}{"email": "alice@example.com", "email_verified": true})
+	if err == nil || err.Error() != "account is suspended" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestOIDCLoginMapsGroupsToRoles(t *testing.T) {
+	o := newOIDCTest(t, []GroupRole{
+		{Group: "library-admins", Role: models.RoleAdmin},
+		{Group: "library-staff", Role: models.RoleLibrarian},
+	})
+	claims := map[string]interface{}{"sub": "bob-id", "email": "bob@example.com", "email_verified": true}
+
+	tests := []struct {
+		groups []string
+		want   models.UserRole
+	}{
+		{[]string{"readers", "library-staff"}, models.RoleLibrarian},
+		// The first mapping the user is in wins
+		{[]string{"library-staff", "library-admins"}, models.RoleAdmin},
+		// Users in no mapped group get the default role
+		{[]string{"readers"}, models.RoleUser},
+		{nil, models.RoleUser},
+	}
+	for _, tt := range tests {
+		claims["groups"] = tt.groups
+		user, _, err := o.signIn(claims)
+		if err != nil {
+			t.Fatalf("groups %v: %v", tt.groups, err)
+		}
+		if user.Role != tt.want {
+			t.Errorf("groups %v: role = %s, want %s", tt.groups, user.Role, tt.want)
+		}
+		if stored := o.users.users[user.ID-1]; stored.Role != tt.want {
+			t.Errorf("groups %v: stored role = %s, want %s", tt.groups, stored.Role, tt.want)
+		}
+	}
+}
+
+func TestOIDCLoginKeepsLocalRolesWithoutMappings(t *testing.T) {
+	o := newOIDCTest(t, nil)
+	o.addUser(models.User{Username: "carol", Email: "carol@example.com", Role: models.RoleLibrarian})
+
+	user, _, err := o.signIn(map[string]interface{}{"email": "carol@example.com", "email_verified": true, "groups": []string{"library-admins"}})
+	if err != nil {
+		t.Fatalf("CompleteLogin: %v", err)
+	}
+	if user.Role != models.RoleLibrarian {
+		t.Errorf("role = %s, want the local role %s", user.Role, models.RoleLibrarian)
+	}
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"example/go_api_tutorial/internal/mailer"
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/utils"
+	"gorm.io/gorm"
+)
+
+// resetQueueSize is how many reset requests may wait for the worker; more are dropped
+const resetQueueSize = 100
+
+// PasswordResetService lets users who forgot their password set a new one through an emailed token
+type PasswordResetService struct {
+	resetRepo      interfaces.PasswordResetRepository
+	userRepo       interfaces.UserRepository
+	userService    *UserService
+	sessionService *SessionService
+	mailer         mailer.Mailer
+	resetURL       string
+	tokenTTL       time.Duration
+	queue          chan string
+}
+
+// NewPasswordResetService creates a new password reset service.
+// resetURL is the page users open from the email, receiving the token as ?token=;
+// tokens stay valid for tokenTTL.
+func NewPasswordResetService(resetRepo interfaces.PasswordResetRepository, userRepo interfaces.UserRepository, userService *UserService, sessionService *SessionService, mailer mailer.Mailer, resetURL string, tokenTTL time.Duration) *PasswordResetService {
+	return &PasswordResetService{
+		resetRepo:      resetRepo,
+		userRepo:       userRepo,
+		userService:    userService,
+		sessionService: sessionService,
+		mailer:         mailer,
+		resetURL:       resetURL,
+		tokenTTL:       tokenTTL,
+		queue:          make(chan string, resetQueueSize),
+	}
+}
+
+// QueueReset queues a RequestReset for the worker started by StartWorker, so callers
+// answer in the same time whether or not the address has an account. It returns
+// false if the queue is full and the request was dropped.
+func (s *PasswordResetService) QueueReset(email string) bool {
+	select {
+	case s.queue <- email:
+		return true
+	default:
+		return false
+	}
+}
+
+// StartWorker handles queued reset requests one at a time in the background.
+// Call the returned function to stop it.
+func (s *PasswordResetService) StartWorker() func() {
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case email := <-s.queue:
+				if err := s.RequestReset(email); err != nil {
+					log.Printf("Failed to send password reset email: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// RequestReset emails a reset token to the account with the given address. Earlier
+// tokens stop working. Unknown addresses are silently ignored so callers can't tell
+// which addresses have accounts.
+func (s *PasswordResetService) RequestReset(email string) error {
+	user, err := s.userRepo.GetByEmail(strings.TrimSpace(strings.ToLower(email)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := s.resetRepo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+	if err := s.resetRepo.DeleteExpired(time.Now()); err != nil {
+		return err
+	}
+
+	token, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	reset := &models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(s.tokenTTL),
+	}
+	if err := s.resetRepo.Create(reset); err != nil {
+		return err
+	}
+
+	link := s.resetURL + "?token=" + url.QueryEscape(token)
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. To choose a new one, open:\n\n%s\n\n"+
+			"The link expires in %s and works once. If you didn't ask for this, you can ignore this email.\n",
+			user.Username, link, s.tokenTTL),
+	})
+}
+
+// ResetPassword redeems a reset token, sets the new password and ends every session of the user
+func (s *PasswordResetService) ResetPassword(token, newPassword string) error {
+	reset, err := s.resetRepo.GetByTokenHash(utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("invalid or expired reset token")
+		}
+		return err
+	}
+	if !reset.IsUsable(time.Now()) {
+		return errors.New("invalid or expired reset token")
+	}
+
+	redeem := func() error {
+		redeemed, err := s.resetRepo.MarkUsed(reset.ID)
+		if err != nil {
+			return err
+		}
+		if !redeemed {
+			return errors.New("invalid or expired reset token")
+		}
+		return nil
+	}
+
+	if err := s.userService.ResetPassword(reset.UserID, newPassword, redeem); err != nil {
+		if err.Error() == "user not found" {
+			return errors.New("invalid or expired reset token")
+		}
+		return err
+	}
+
+	return s.sessionService.RevokeAllForUser(reset.UserID)
+}
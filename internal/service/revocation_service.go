@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/utils"
+)
+
+// RevocationService keeps the deny-list of revoked access tokens.
+// Entries are persisted so every instance sees them, and cached in memory so
+// checking a token on each request doesn't hit the database.
+type RevocationService struct {
+	revokedRepo interfaces.RevokedTokenRepository
+	tokenTTL    time.Duration
+
+	mu     sync.RWMutex
+	denied map[string]time.Time
+}
+
+// NewRevocationService creates a new revocation service.
+// tokenTTL is the access token lifetime: a deny-list entry is kept that long.
+func NewRevocationService(revokedRepo interfaces.RevokedTokenRepository, tokenTTL time.Duration) *RevocationService {
+	return &RevocationService{
+		revokedRepo: revokedRepo,
+		tokenTTL:    tokenTTL,
+		denied:      make(map[string]time.Time),
+	}
+}
+
+// Revoke denies a token ID (jti) or session ID (sid) until every token carrying it has expired
+func (s *RevocationService) Revoke(identifier string) error {
+	if identifier == "" {
+		return nil
+	}
+
+	expiresAt := time.Now().Add(s.tokenTTL)
+	err := s.revokedRepo.Create(&models.RevokedToken{
+		Identifier: identifier,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.denied[identifier] = expiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+// IsRevoked checks the in-memory deny-list for an identifier
+func (s *RevocationService) IsRevoked(identifier string) bool {
+	s.mu.RLock()
+	expiresAt, ok := s.denied[identifier]
+	s.mu.RUnlock()
+	return ok && time.Now().Before(expiresAt)
+}
+
+// VerifyClaims rejects tokens whose jti or session has been revoked
+func (s *RevocationService) VerifyClaims(claims *utils.JWTClaims) error {
+	if s.IsRevoked(claims.ID) || s.IsRevoked(claims.SessionID) {
+		return errors.New("token has been revoked")
+	}
+	return nil
+}
+
+// Sync reloads the deny-list from the database, picking up revocations made
+// by other instances and dropping expired entries
+func (s *RevocationService) Sync() error {
+	now := time.Now()
+	if err := s.revokedRepo.DeleteExpired(now); err != nil {
+		return err
+	}
+
+	entries, err := s.revokedRepo.GetActive(now)
+	if err != nil {
+		return err
+	}
+
+	denied := make(map[string]time.Time, len(entries))
+	for _, entry := range entries {
+		denied[entry.Identifier] = entry.ExpiresAt
+	}
+
+	s.mu.Lock()
+	// Keep local revocations that raced with the reload
+	for identifier, expiresAt := range s.denied {
+		if _, ok := denied[identifier]; !ok && now.Before(expiresAt) {
+			denied[identifier] = expiresAt
+		}
+	}
+	s.denied = denied
+	s.mu.Unlock()
+	return nil
+}
+
+// StartSync runs Sync on an interval in the background.
+// Call the returned function to stop it.
+func (s *RevocationService) StartSync(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Sync(); err != nil {
+					log.Printf("Failed to sync token deny-list: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
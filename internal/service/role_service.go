@@ -0,0 +1,239 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"gorm.io/gorm"
+)
+
+// roleNamePattern restricts custom role names to lowercase identifiers
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+
+// RoleService handles business logic for roles and permissions
+type RoleService struct {
+	roleRepo interfaces.RoleRepository
+	cacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[models.UserRole]rolePermissionsEntry
+}
+
+type rolePermissionsEntry struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+// NewRoleService creates a new role service.
+// cacheTTL is how long a role's permissions are cached for authorization checks.
+func NewRoleService(roleRepo interfaces.RoleRepository, cacheTTL time.Duration) *RoleService {
+	return &RoleService{
+		roleRepo: roleRepo,
+		cacheTTL: cacheTTL,
+		cache:    make(map[models.UserRole]rolePermissionsEntry),
+	}
+}
+
+// Permissions returns the permission names granted to a role.
+// An unknown role has no permissions.
+func (s *RoleService) Permissions(role models.UserRole) ([]string, error) {
+	s.mu.Lock()
+	entry, ok := s.cache[role]
+	s.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.permissions, nil
+	}
+
+	var permissions []string
+	found, err := s.roleRepo.GetByName(role)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if found != nil {
+		permissions = found.PermissionNames()
+	}
+
+	s.mu.Lock()
+	s.cache[role] = rolePermissionsEntry{permissions: permissions, expiresAt: time.Now().Add(s.cacheTTL)}
+	s.mu.Unlock()
+	return permissions, nil
+}
+
+// HasPermission checks if a role grants a permission
+func (s *RoleService) HasPermission(role models.UserRole, permission string) (bool, error) {
+	permissions, err := s.Permissions(role)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ScopesForRole returns the token scopes covering a role's permissions
+func (s *RoleService) ScopesForRole(role models.UserRole) ([]models.Scope, error) {
+	permissions, err := s.Permissions(role)
+	if err != nil {
+		return nil, err
+	}
+	return models.ScopesForPermissions(permissions), nil
+}
+
+// RoleExists checks if a role with the given name exists
+func (s *RoleService) RoleExists(role models.UserRole) (bool, error) {
+	_, err := s.roleRepo.GetByName(role)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetAllRoles returns all roles with their permissions
+func (s *RoleService) GetAllRoles() ([]models.Role, error) {
+	return s.roleRepo.GetAll()
+}
+
+// GetAllPermissions returns every known permission
+func (s *RoleService) GetAllPermissions() ([]models.Permission, error) {
+	return s.roleRepo.GetAllPermissions()
+}
+
+// CreateRole creates a custom role with the given permissions
+func (s *RoleService) CreateRole(name models.UserRole, description string, permissionNames []string) (*models.Role, error) {
+	name = models.UserRole(strings.TrimSpace(string(name)))
+	if !roleNamePattern.MatchString(string(name)) {
+		return nil, errors.New("role name must be 2-20 lowercase letters, digits, '-' or '_'")
+	}
+
+	exists, err := s.RoleExists(name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("role already exists")
+	}
+
+	permissions, err := s.resolvePermissions(permissionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        name,
+		Description: strings.TrimSpace(description),
+		Permissions: permissions,
+	}
+	if err := s.roleRepo.Create(role); err != nil {
+		return nil, err
+	}
+
+	s.invalidate(name)
+	return role, nil
+}
+
+// SetRolePermissions replaces the permissions of a role.
+// The admin role always keeps every permission and cannot be changed.
+func (s *RoleService) SetRolePermissions(name models.UserRole, permissionNames []string) (*models.Role, error) {
+	role, err := s.getRole(name)
+	if err != nil {
+		return nil, err
+	}
+	if role.Name == models.RoleAdmin {
+		return nil, errors.New("the admin role cannot be changed")
+	}
+
+	permissions, err := s.resolvePermissions(permissionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.roleRepo.SetPermissions(role, permissions); err != nil {
+		return nil, err
+	}
+
+	s.invalidate(name)
+	return s.roleRepo.GetByName(name)
+}
+
+// DeleteRole deletes a custom role that no user is assigned
+func (s *RoleService) DeleteRole(name models.UserRole) error {
+	role, err := s.getRole(name)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return errors.New("built-in roles cannot be deleted")
+	}
+
+	count, err := s.roleRepo.CountUsersWithRole(name)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("role is still assigned to users")
+	}
+
+	if err := s.roleRepo.Delete(role); err != nil {
+		return err
+	}
+
+	s.invalidate(name)
+	return nil
+}
+
+// getRole returns a role by name
+func (s *RoleService) getRole(name models.UserRole) (*models.Role, error) {
+	role, err := s.roleRepo.GetByName(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("role not found")
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
+// resolvePermissions looks up permissions by name, rejecting unknown names
+func (s *RoleService) resolvePermissions(names []string) ([]models.Permission, error) {
+	if len(names) == 0 {
+		return []models.Permission{}, nil
+	}
+
+	permissions, err := s.roleRepo.GetPermissionsByNames(names)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		found := false
+		for _, permission := range permissions {
+			if permission.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("unknown permission: " + name)
+		}
+	}
+
+	return permissions, nil
+}
+
+// invalidate drops a role's cached permissions after it changed
+func (s *RoleService) invalidate(name models.UserRole) {
+	s.mu.Lock()
+	delete(s.cache, name)
+	s.mu.Unlock()
+}
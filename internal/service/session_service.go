@@ -0,0 +1,250 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/utils"
+	"gorm.io/gorm"
+)
+
+// TokenPair is a short-lived access token and the refresh token to renew it
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// SessionService handles refresh token sessions and their rotation
+type SessionService struct {
+	sessionRepo interfaces.SessionRepository
+	userRepo    interfaces.UserRepository
+	revocations *RevocationService
+	roleService *RoleService
+	jwtManager  *utils.JWTManager
+	refreshTTL  time.Duration
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(sessionRepo interfaces.SessionRepository, userRepo interfaces.UserRepository, revocations *RevocationService, roleService *RoleService, jwtManager *utils.JWTManager, refreshTTL time.Duration) *SessionService {
+	return &SessionService{
+		sessionRepo: sessionRepo,
+		userRepo:    userRepo,
+		revocations: revocations,
+		roleService: roleService,
+		jwtManager:  jwtManager,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+// StartSession opens a new session (token family) for a freshly authenticated user.
+// twoFactor records whether the login passed a second factor; tokens of the session carry it.
+func (s *SessionService) StartSession(user *models.User, twoFactor bool, userAgent, ipAddress string) (*TokenPair, error) {
+	familyID, err := utils.RandomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, session, err := s.newSession(user, familyID, time.Now(), twoFactor, userAgent, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.tokenPair(user, familyID, refreshToken, twoFactor)
+}
+
+// Refresh exchanges a refresh token for a new token pair, rotating the refresh token.
+// Presenting a token that was already rotated means it leaked, so the whole family is revoked.
+func (s *SessionService) Refresh(refreshToken, userAgent, ipAddress string) (*models.User, *TokenPair, error) {
+	session, err := s.sessionRepo.GetByTokenHash(utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("invalid refresh token")
+		}
+		return nil, nil, err
+	}
+
+	if session.RotatedAt != nil {
+		log.Printf("Refresh token reuse detected for user %d, revoking session family %s", session.UserID, session.FamilyID)
+		if err := s.revokeFamily(session.FamilyID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("invalid refresh token")
+	}
+	if !session.IsUsable(time.Now()) {
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("invalid refresh token")
+		}
+		return nil, nil, err
+	}
+
+	// Tokens were invalidated after this session started (e.g. password change or suspension)
+	if user.TokenVersion != session.TokenVersion || user.IsSuspended() {
+		if err := s.revokeFamily(session.FamilyID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, errors.New("invalid refresh token")
+	}
+
+	newRefreshToken, next, err := s.newSession(user, session.FamilyID, session.StartedAt, session.TwoFactor, userAgent, ipAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.sessionRepo.Rotate(session, next); err != nil {
+		if errors.Is(err, interfaces.ErrSessionNotUsable) {
+			// Lost a race with another refresh of the same token
+			if err := s.revokeFamily(session.FamilyID); err != nil {
+				return nil, nil, err
+			}
+			return nil, nil, errors.New("invalid refresh token")
+		}
+		return nil, nil, err
+	}
+
+	pair, err := s.tokenPair(user, session.FamilyID, newRefreshToken, session.TwoFactor)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, pair, nil
+}
+
+// ListSessions returns the active logins of a user
+func (s *SessionService) ListSessions(userID uint) ([]models.Session, error) {
+	return s.sessionRepo.GetActiveByUser(userID)
+}
+
+// SignedInAt returns when the login an access token belongs to started, or the zero
+// time if the token belongs to no live login
+func (s *SessionService) SignedInAt(claims *utils.JWTClaims) (time.Time, error) {
+	if claims.SessionID == "" {
+		return time.Time{}, nil
+	}
+
+	sessions, err := s.sessionRepo.GetActiveByUser(claims.UserID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, session := range sessions {
+		if session.FamilyID == claims.SessionID {
+			return session.StartedAt, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// RevokeSession ends one of the user's own logins
+func (s *SessionService) RevokeSession(userID uint, familyID string) error {
+	sessions, err := s.sessionRepo.GetActiveByUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.FamilyID == familyID {
+			return s.revokeFamily(familyID)
+		}
+	}
+	return errors.New("session not found")
+}
+
+// Logout ends the session the access token belongs to and denies the token itself
+func (s *SessionService) Logout(claims *utils.JWTClaims) error {
+	if err := s.revocations.Revoke(claims.ID); err != nil {
+		return err
+	}
+	if claims.SessionID == "" {
+		return nil
+	}
+	return s.revokeFamily(claims.SessionID)
+}
+
+// RevokeAllForUser ends every login of a user, cutting off their access tokens immediately
+func (s *SessionService) RevokeAllForUser(userID uint) error {
+	familyIDs, err := s.sessionRepo.GetLiveFamilyIDs(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, familyID := range familyIDs {
+		if err := s.revocations.Revoke(familyID); err != nil {
+			return err
+		}
+	}
+	return s.sessionRepo.RevokeAllForUser(userID)
+}
+
+// revokeFamily revokes the refresh tokens of a login and denies its access tokens
+func (s *SessionService) revokeFamily(familyID string) error {
+	if err := s.revocations.Revoke(familyID); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeFamily(familyID)
+}
+
+// newSession builds a session row for a new refresh token in the given family
+func (s *SessionService) newSession(user *models.User, familyID string, startedAt time.Time, twoFactor bool, userAgent, ipAddress string) (string, *models.Session, error) {
+	refreshToken, tokenHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		UserID:       user.ID,
+		FamilyID:     familyID,
+		TokenHash:    tokenHash,
+		TokenVersion: user.TokenVersion,
+		Device:       truncate(utils.DeviceFromUserAgent(userAgent), 100),
+		UserAgent:    truncate(userAgent, 255),
+		IPAddress:    truncate(ipAddress, 45),
+		TwoFactor:    twoFactor,
+		StartedAt:    startedAt,
+		ExpiresAt:    now.Add(s.refreshTTL),
+		LastSeenAt:   now,
+	}
+	return refreshToken, session, nil
+}
+
+// tokenPair signs an access token for the session and pairs it with the refresh token
+func (s *SessionService) tokenPair(user *models.User, familyID, refreshToken string, twoFactor bool) (*TokenPair, error) {
+	scopes, err := s.roleService.ScopesForRole(user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	amr := []string{utils.AMRPassword}
+	if twoFactor {
+		amr = append(amr, utils.AMROTP)
+	}
+
+	accessToken, err := s.jwtManager.GenerateToken(user, familyID, scopes, amr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.jwtManager.ExpiresIn(),
+	}, nil
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"time"
+
+	"example/go_api_tutorial/internal/repository/interfaces"
+)
+
+// CatalogStats summarizes the catalog and how much of it is in circulation
+type CatalogStats struct {
+	Titles       int64 `json:"titles"`
+	Copies       int64 `json:"copies"`
+	Available    int64 `json:"available"`
+	ActiveLoans  int64 `json:"active_loans"`
+	OverdueLoans int64 `json:"overdue_loans"`
+	WaitingHolds int64 `json:"waiting_holds"`
+	ReadyHolds   int64 `json:"ready_holds"`
+}
+
+// StatsService reports figures about the library
+type StatsService struct {
+	bookRepo interfaces.BookRepository
+	loanRepo interfaces.LoanRepository
+	holdRepo interfaces.HoldRepository
+}
+
+// NewStatsService creates a new stats service
+func NewStatsService(bookRepo interfaces.BookRepository, loanRepo interfaces.LoanRepository, holdRepo interfaces.HoldRepository) *StatsService {
+	return &StatsService{
+		bookRepo: bookRepo,
+		loanRepo: loanRepo,
+		holdRepo: holdRepo,
+	}
+}
+
+// GetCatalogStats counts the books, copies, loans and holds. The counts come from
+// separate queries, so they may not add up exactly while books are being lent.
+func (s *StatsService) GetCatalogStats() (*CatalogStats, error) {
+	var stats CatalogStats
+	var err error
+
+	if stats.Titles, stats.Copies, stats.Available, err = s.bookRepo.Totals(); err != nil {
+		return nil, err
+	}
+	if stats.ActiveLoans, stats.OverdueLoans, err = s.loanRepo.CountActive(time.Now()); err != nil {
+		return nil, err
+	}
+	if stats.WaitingHolds, stats.ReadyHolds, err = s.holdRepo.CountActive(); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
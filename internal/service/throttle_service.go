@@ -0,0 +1,188 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+)
+
+// ThrottledError is returned when an attempt is refused until RetryAfter has passed
+type ThrottledError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return e.Message
+}
+
+// LockoutPolicy controls how failed logins slow down and lock an account
+type LockoutPolicy struct {
+	MaxAttempts     int           // failures that lock the account for LockoutDuration
+	BackoffAfter    int           // failures after which each further failure imposes a growing delay
+	BackoffBase     time.Duration // delay after the BackoffAfter-th failure, doubling with each failure
+	LockoutDuration time.Duration
+	Window          time.Duration // failures older than this are forgotten
+}
+
+// ThrottleService limits login guessing per account and requests per client IP
+type ThrottleService struct {
+	store  interfaces.LoginAttemptStore
+	policy LockoutPolicy
+}
+
+// NewThrottleService creates a new throttle service
+func NewThrottleService(store interfaces.LoginAttemptStore, policy LockoutPolicy) *ThrottleService {
+	return &ThrottleService{
+		store:  store,
+		policy: policy,
+	}
+}
+
+// CheckAccount refuses a login for an account that is locked or backing off
+func (s *ThrottleService) CheckAccount(userID uint) error {
+	now := time.Now()
+	attempt, err := s.store.Get(accountKey(userID), now)
+	if err != nil {
+		return err
+	}
+	if attempt != nil && attempt.IsLocked(now) {
+		return &ThrottledError{
+			Message:    "too many failed login attempts, try again later",
+			RetryAfter: attempt.LockedUntil.Sub(now),
+		}
+	}
+	return nil
+}
+
+// RecordLoginFailure counts a failed login. Once BackoffAfter failures have been
+// made each failure locks the account for twice as long as the previous one, and
+// MaxAttempts failures lock it for the full lockout duration.
+func (s *ThrottleService) RecordLoginFailure(userID uint) error {
+	now := time.Now()
+	key := accountKey(userID)
+
+	attempt, err := s.store.Increment(key, s.policy.Window, now)
+	if err != nil {
+		return err
+	}
+
+	var delay time.Duration
+	switch {
+	case s.policy.MaxAttempts > 0 && attempt.Count >= s.policy.MaxAttempts:
+		delay = s.policy.LockoutDuration
+	case s.policy.BackoffAfter > 0 && attempt.Count >= s.policy.BackoffAfter:
+		delay = backoff(s.policy.BackoffBase, attempt.Count-s.policy.BackoffAfter, s.policy.LockoutDuration)
+	default:
+		return nil
+	}
+
+	if delay <= 0 {
+		return nil
+	}
+	return s.store.Lock(key, now.Add(delay))
+}
+
+// ResetAccount forgets an account's failed logins and lifts any lock
+func (s *ThrottleService) ResetAccount(userID uint) error {
+	return s.store.Reset(accountKey(userID))
+}
+
+// AccountStatus returns an account's failed logins and lock
+func (s *ThrottleService) AccountStatus(userID uint) (*models.LockoutStatus, error) {
+	now := time.Now()
+	attempt, err := s.store.Get(accountKey(userID), now)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.LockoutStatus{}
+	if attempt == nil {
+		return status, nil
+	}
+	status.FailedAttempts = attempt.Count
+	if attempt.IsLocked(now) {
+		status.Locked = true
+		status.LockedUntil = attempt.LockedUntil
+	}
+	return status, nil
+}
+
+// RateLimit returns a limiter allowing each client limit requests per window for an action
+func (s *ThrottleService) RateLimit(action string, limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		store:  s.store,
+		action: action,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// StartCleanup removes ended counters on an interval in the background.
+// Call the returned function to stop it.
+func (s *ThrottleService) StartCleanup(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.store.DeleteExpired(time.Now()); err != nil {
+					log.Printf("Failed to clean up login attempts: %v", err)
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// RateLimiter counts an action's requests per client in fixed windows
+type RateLimiter struct {
+	store  interfaces.LoginAttemptStore
+	action string
+	limit  int
+	window time.Duration
+}
+
+// Allow counts a request from client and returns how long it has to wait
+// when it is over the limit, or zero when the request may proceed
+func (l *RateLimiter) Allow(client string) (time.Duration, error) {
+	if l.limit <= 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	attempt, err := l.store.Increment(fmt.Sprintf("%s:%s", l.action, client), l.window, now)
+	if err != nil {
+		return 0, err
+	}
+	if attempt.Count <= l.limit {
+		return 0, nil
+	}
+	return attempt.WindowEndsAt.Sub(now), nil
+}
+
+// accountKey is the attempt store key for an account's failed logins
+func accountKey(userID uint) string {
+	return fmt.Sprintf("account:%d", userID)
+}
+
+// backoff doubles base for each step, capped at max
+func backoff(base time.Duration, step int, max time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < step && delay < max; i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		return max
+	}
+	return delay
+}
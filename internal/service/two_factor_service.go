@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// twoFactorChallenge is the purpose of the challenge token handed out after a correct password
+	twoFactorChallenge = "2fa"
+	recoveryCodeCount  = 10
+)
+
+// TwoFactorService handles TOTP enrollment and the second step of logging in
+type TwoFactorService struct {
+	userRepo        interfaces.UserRepository
+	throttleService *ThrottleService
+	jwtManager      *utils.JWTManager
+	issuer          string
+	challengeTTL    time.Duration
+	requiredRoles   []models.UserRole
+}
+
+// NewTwoFactorService creates a new two-factor service.
+// issuer is the name authenticator apps show; challengeTTL is how long a user has to
+// enter their code after the password; users with a role in requiredRoles get no
+// permissions until they log in with a second factor.
+func NewTwoFactorService(userRepo interfaces.UserRepository, throttleService *ThrottleService, jwtManager *utils.JWTManager, issuer string, challengeTTL time.Duration, requiredRoles []models.UserRole) *TwoFactorService {
+	return &TwoFactorService{
+		userRepo:        userRepo,
+		throttleService: throttleService,
+		jwtManager:      jwtManager,
+		issuer:          issuer,
+		challengeTTL:    challengeTTL,
+		requiredRoles:   requiredRoles,
+	}
+}
+
+// Setup generates a new TOTP secret for the user and returns it with its otpauth URI.
+// Two-factor login only starts once a code from the secret is confirmed with Enable.
+func (s *TwoFactorService) Setup(userID uint) (string, string, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", "", errors.New("user not found")
+		}
+		return "", "", err
+	}
+	if user.TwoFactorEnabled {
+		return "", "", errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.userRepo.SetTOTPSecret(userID, secret); err != nil {
+		if errors.Is(err, interfaces.ErrTwoFactorEnabled) {
+			return "", "", errors.New("two-factor authentication is already enabled")
+		}
+		return "", "", err
+	}
+
+	return secret, utils.TOTPURI(s.issuer, user.Username, secret), nil
+}
+
+// Enable confirms enrollment with a code from the authenticator app, turns two-factor
+// login on and returns the recovery codes. They are only shown this once.
+func (s *TwoFactorService) Enable(userID uint, code string) ([]string, error) {
+	user, err := s.userRepo.GetByIDWithCredentials(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor setup has not been started")
+	}
+
+	valid, err := s.useTOTPCode(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, errors.New("invalid two-factor code")
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+	}
+
+	if err := s.userRepo.EnableTwoFactor(userID, user.TOTPSecret, hashes); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Setup was restarted since the code was generated
+			return nil, errors.New("invalid two-factor code")
+		}
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// IsRequired checks if users with the role must log in with a second factor
+func (s *TwoFactorService) IsRequired(role models.UserRole) bool {
+	for _, required := range s.requiredRoles {
+		if required == role {
+			return true
+		}
+	}
+	return false
+}
+
+// StartChallenge returns a short-lived token to exchange, together with a code,
+// for a session once the password has been checked
+func (s *TwoFactorService) StartChallenge(user *models.User) (string, error) {
+	return s.jwtManager.GenerateChallengeToken(user, twoFactorChallenge, s.challengeTTL)
+}
+
+// ChallengeTTL returns how long challenge tokens stay valid
+func (s *TwoFactorService) ChallengeTTL() time.Duration {
+	return s.challengeTTL
+}
+
+// CompleteChallenge checks a TOTP or recovery code against a challenge token and
+// returns the user to start a session for. Wrong codes count towards the account lockout.
+func (s *TwoFactorService) CompleteChallenge(challengeToken, code string) (*models.User, error) {
+	claims, err := s.jwtManager.ValidateChallengeToken(challengeToken, twoFactorChallenge)
+	if err != nil {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	user, err := s.userRepo.GetByIDWithCredentials(claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid or expired challenge")
+		}
+		return nil, err
+	}
+	// The password changed or the account was suspended since the challenge was issued
+	if user.TokenVersion != claims.TokenVersion || !user.TwoFactorEnabled || user.IsSuspended() {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	if err := s.throttleService.CheckAccount(user.ID); err != nil {
+		return nil, err
+	}
+
+	valid, err := s.useTOTPCode(user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		valid, err = s.userRepo.UseRecoveryCode(user.ID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !valid {
+		if err := s.throttleService.RecordLoginFailure(user.ID); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid two-factor code")
+	}
+
+	if err := s.throttleService.ResetAccount(user.ID); err != nil {
+		return nil, err
+	}
+
+	// Clear credentials before returning
+	user.Password = ""
+	user.TOTPSecret = ""
+	return user, nil
+}
+
+// VerifyClaims withholds every scope from tokens of users whose role requires a second
+// factor when the login didn't use one, leaving them only able to enroll
+func (s *TwoFactorService) VerifyClaims(claims *utils.JWTClaims) error {
+	if s.IsRequired(claims.Role) && !claims.HasAMR(utils.AMROTP) {
+		claims.Scopes = []models.Scope{}
+	}
+	return nil
+}
+
+// useTOTPCode checks a TOTP code and marks its time step used, so each code works once
+func (s *TwoFactorService) useTOTPCode(user *models.User, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return s.userRepo.UseTOTPStep(user.ID, step)
+}
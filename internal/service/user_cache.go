@@ -0,0 +1,62 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"example/go_api_tutorial/internal/models"
+)
+
+// userCache is a short-lived in-process cache of user records keyed by user ID.
+// A nil user is cached too, so tokens of deleted users are rejected cheaply.
+type userCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[uint]userCacheEntry
+}
+
+type userCacheEntry struct {
+	user      *models.User
+	expiresAt time.Time
+}
+
+// newUserCache creates a cache whose entries live for ttl
+func newUserCache(ttl time.Duration) *userCache {
+	return &userCache{
+		ttl:     ttl,
+		entries: make(map[uint]userCacheEntry),
+	}
+}
+
+// get returns the cached user and whether a fresh entry was found
+func (c *userCache) get(id uint) (*models.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, id)
+		return nil, false
+	}
+	return entry.user, true
+}
+
+// set caches a user record (nil for a user that doesn't exist)
+func (c *userCache) set(id uint, user *models.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[id] = userCacheEntry{user: user, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// invalidate drops a user from the cache after it changed
+func (c *userCache) invalidate(id uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, id)
+}
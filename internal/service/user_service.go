@@ -2,7 +2,10 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
 	"strings"
+	"time"
 
 	"example/go_api_tutorial/internal/models"
 	"example/go_api_tutorial/internal/repository/interfaces"
@@ -10,20 +13,121 @@ import (
 	"gorm.io/gorm"
 )
 
+// Email verification modes: what users who haven't confirmed their email address may do
+const (
+	EmailVerificationOff   = "off"   // everything
+	EmailVerificationLogin = "login" // nothing, they can't log in
+	EmailVerificationWrite = "write" // log in and read, but not change anything
+)
+
+// recentSignInWindow is how long after signing in a user without a password may
+// confirm changes that would otherwise need it
+const recentSignInWindow = 5 * time.Minute
+
+// UserPage is a page of a user listing
+type UserPage struct {
+	Users    []models.User
+	Total    int64 // users matching the filter
+	Page     int
+	PageSize int
+}
+
 // UserService handles business logic for users
 type UserService struct {
-	userRepo interfaces.UserRepository
+	userRepo            interfaces.UserRepository
+	roleService         *RoleService
+	throttleService     *ThrottleService
+	passwordHistorySize int
+	cache               *userCache
+	emailVerification   string
+	passwordPolicy      utils.PasswordPolicy
+	hasher              utils.PasswordHasher
 }
 
-// NewUserService creates a new user service
-func NewUserService(userRepo interfaces.UserRepository) *UserService {
+// NewUserService creates a new user service.
+// passwordHistorySize is how many previous passwords a user may not reuse;
+// cacheTTL is how long user records are cached when checking tokens.
+func NewUserService(userRepo interfaces.UserRepository, roleService *RoleService, throttleService *ThrottleService, passwordHistorySize int, cacheTTL time.Duration) *UserService {
 	return &UserService{
-		userRepo: userRepo,
+		userRepo:            userRepo,
+		roleService:         roleService,
+		throttleService:     throttleService,
+		passwordHistorySize: passwordHistorySize,
+		cache:               newUserCache(cacheTTL),
+		emailVerification:   EmailVerificationOff,
+		passwordPolicy:      utils.DefaultPasswordPolicy(),
+		hasher:              utils.DefaultPasswordHasher(),
 	}
 }
 
+// WithPasswordHasher sets how new passwords are hashed. Existing hashes of other
+// algorithms or costs keep working and are upgraded when their user logs in.
+func (s *UserService) WithPasswordHasher(hasher utils.PasswordHasher) *UserService {
+	s.hasher = hasher
+	return s
+}
+
+// WithPasswordPolicy sets the rules new passwords are checked against
+func (s *UserService) WithPasswordPolicy(policy utils.PasswordPolicy) *UserService {
+	s.passwordPolicy = policy
+	return s
+}
+
+// RequireVerifiedEmail sets what users with an unverified email address are kept from
+// doing, one of EmailVerificationOff, EmailVerificationLogin or EmailVerificationWrite
+func (s *UserService) RequireVerifiedEmail(mode string) *UserService {
+	s.emailVerification = mode
+	return s
+}
+
+// EmailVerificationMode returns what users with an unverified email address are kept from doing
+func (s *UserService) EmailVerificationMode() string {
+	return s.emailVerification
+}
+
 // RegisterUser creates a new user account
 func (s *UserService) RegisterUser(username, email, password string) (*models.User, error) {
+	user, err := s.newUser(username, email, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateUser creates an account on behalf of an operator, with the given role and
+// the email address taken as verified
+func (s *UserService) CreateUser(username, email, password string, role models.UserRole) (*models.User, error) {
+	exists, err := s.roleService.RoleExists(role)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("invalid role")
+	}
+
+	user, err := s.newUser(username, email, password)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	user.Role = role
+	user.EmailVerifiedAt = &now
+
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// newUser validates registration details and builds the user to create, with the
+// default role and the password hashed
+func (s *UserService) newUser(username, email, password string) (*models.User, error) {
 	// Validate input
 	if strings.TrimSpace(username) == "" {
 		return nil, errors.New("username is required")
@@ -31,7 +135,7 @@ func (s *UserService) RegisterUser(username, email, password string) (*models.Us
 	if strings.TrimSpace(email) == "" {
 		return nil, errors.New("email is required")
 	}
-	if err := utils.ValidatePassword(password); err != nil {
+	if err := s.passwordPolicy.Validate(password, username, email); err != nil {
 		return nil, err
 	}
 
@@ -54,27 +158,22 @@ func (s *UserService) RegisterUser(username, email, password string) (*models.Us
 	}
 
 	// Hash password
-	hashedPassword, err := utils.HashPassword(password)
+	hashedPassword, err := s.hasher.Hash(password)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create user
-	user := &models.User{
+	return &models.User{
 		Username: strings.TrimSpace(username),
 		Email:    strings.TrimSpace(strings.ToLower(email)),
 		Password: hashedPassword,
 		Role:     models.RoleUser, // Default role
-	}
-
-	if err := s.userRepo.Create(user); err != nil {
-		return nil, err
-	}
-
-	return user, nil
+	}, nil
 }
 
-// LoginUser authenticates a user and returns user info (without password)
+// LoginUser authenticates a user and returns user info (without password).
+// Repeated failures slow down and then lock the account; a locked account
+// returns a *ThrottledError without checking the password.
 func (s *UserService) LoginUser(usernameOrEmail, password string) (*models.User, error) {
 	if strings.TrimSpace(usernameOrEmail) == "" {
 		return nil, errors.New("username or email is required")
@@ -100,13 +199,47 @@ func (s *UserService) LoginUser(usernameOrEmail, password string) (*models.User,
 		return nil, err
 	}
 
+	// Refuse guesses while the account is locked
+	if err := s.throttleService.CheckAccount(user.ID); err != nil {
+		return nil, err
+	}
+
 	// Check password
-	if err := utils.CheckPassword(password, user.Password); err != nil {
+	valid, err := s.hasher.Verify(password, user.Password)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		if err := s.throttleService.RecordLoginFailure(user.ID); err != nil {
+			return nil, err
+		}
 		return nil, errors.New("invalid credentials")
 	}
 
-	// Clear password before returning
+	// Upgrade hashes made with an old algorithm or cost while the password is at hand
+	if s.hasher.NeedsRehash(user.Password) {
+		s.rehashPassword(user.ID, password, user.Password)
+	}
+
+	// With 2FA on, failures are only forgotten once the second step succeeds,
+	// so a known password doesn't reset the lockout for code guesses
+	if !user.TwoFactorEnabled {
+		if err := s.throttleService.ResetAccount(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if user.IsSuspended() {
+		return nil, errors.New("account is suspended")
+	}
+
+	if s.emailVerification == EmailVerificationLogin && !user.IsEmailVerified() {
+		return nil, errors.New("email address has not been verified")
+	}
+
+	// Clear credentials before returning
 	user.Password = ""
+	user.TOTPSecret = ""
 	return user, nil
 }
 
@@ -122,9 +255,211 @@ func (s *UserService) GetUserByID(id uint) (*models.User, error) {
 	return user, nil
 }
 
-// GetAllUsers returns all users (admin only)
-func (s *UserService) GetAllUsers() ([]models.User, error) {
-	return s.userRepo.GetAll()
+// GetUserByUsername returns a user by username
+func (s *UserService) GetUserByUsername(username string) (*models.User, error) {
+	user, err := s.userRepo.GetByUsername(strings.TrimSpace(username))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// VerifyEmail marks the user's email address as confirmed, if it is still the given one
+func (s *UserService) VerifyEmail(id uint, email string) error {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("invalid or expired verification link")
+		}
+		return err
+	}
+	if user.Email != email {
+		return errors.New("invalid or expired verification link")
+	}
+	if user.IsEmailVerified() {
+		return errors.New("email address is already verified")
+	}
+
+	if _, err := s.userRepo.MarkEmailVerified(id, email); err != nil {
+		return err
+	}
+
+	// Lift write restrictions on existing tokens right away
+	s.cache.invalidate(id)
+	return nil
+}
+
+// UpdateProfile changes a user's username and/or email address; nil leaves a field as is.
+// Changing the email address needs the user to confirm their identity (see confirmIdentity)
+// with currentPassword or signedInAt. The new address has to be verified again;
+// previousEmail is the old address when it changed, and empty otherwise.
+func (s *UserService) UpdateProfile(userID uint, username, email *string, currentPassword string, signedInAt time.Time) (user *models.User, previousEmail string, err error) {
+	user, err = s.userRepo.GetByIDWithCredentials(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", errors.New("user not found")
+		}
+		return nil, "", err
+	}
+
+	var newUsername, newEmail *string
+	if username != nil {
+		trimmed := strings.TrimSpace(*username)
+		if trimmed == "" {
+			return nil, "", errors.New("username is required")
+		}
+		if trimmed != user.Username {
+			exists, err := s.userRepo.ExistsByUsername(trimmed)
+			if err != nil {
+				return nil, "", err
+			}
+			if exists {
+				return nil, "", errors.New("username already exists")
+			}
+			newUsername = &trimmed
+		}
+	}
+
+	if email != nil {
+		normalized := strings.TrimSpace(strings.ToLower(*email))
+		if normalized == "" {
+			return nil, "", errors.New("email is required")
+		}
+		if normalized != user.Email {
+			// Whoever controls the address can reset the password, so only the holder may move it
+			if err := s.confirmIdentity(user, currentPassword, signedInAt); err != nil {
+				return nil, "", err
+			}
+			exists, err := s.userRepo.ExistsByEmail(normalized)
+			if err != nil {
+				return nil, "", err
+			}
+			if exists {
+				return nil, "", errors.New("email already exists")
+			}
+			newEmail = &normalized
+		}
+	}
+
+	if err := s.userRepo.UpdateProfile(userID, newUsername, newEmail); err != nil {
+		// Taken by another request since the checks above
+		if errors.Is(err, interfaces.ErrUsernameOrEmailTaken) {
+			return nil, "", errors.New("username or email already exists")
+		}
+		return nil, "", err
+	}
+	if newEmail != nil {
+		previousEmail = user.Email
+	}
+
+	// Tokens carry the username and email, and may lose write access until the new address is verified
+	s.cache.invalidate(userID)
+
+	user, err = s.GetUserByID(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	return user, previousEmail, nil
+}
+
+// GetUserWithLockout returns a user by ID along with their login lockout state
+func (s *UserService) GetUserWithLockout(id uint) (*models.User, error) {
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	lockout, err := s.throttleService.AccountStatus(id)
+	if err != nil {
+		return nil, err
+	}
+	user.Lockout = lockout
+	return user, nil
+}
+
+// UnlockUser lifts a login lockout and forgets the user's failed logins
+func (s *UserService) UnlockUser(id uint) error {
+	if _, err := s.GetUserByID(id); err != nil {
+		return err
+	}
+	return s.throttleService.ResetAccount(id)
+}
+
+// ListUsers returns a page of users matching the filter (admin only).
+// Pages are numbered from 1 and hold 20 users unless pageSize is between 1 and 100;
+// the page returned has the page number and size actually used.
+func (s *UserService) ListUsers(filter interfaces.UserFilter, page, pageSize int) (*UserPage, error) {
+	switch filter.Status {
+	case "", interfaces.UserStatusActive, interfaces.UserStatusSuspended:
+	default:
+		return nil, errors.New("invalid status")
+	}
+	switch filter.SortBy {
+	case "", "id", "username", "email", "created_at":
+	default:
+		return nil, errors.New("invalid sort field")
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	filter.Offset = (page - 1) * pageSize
+	filter.Limit = pageSize
+	users, total, err := s.userRepo.Search(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &UserPage{Users: users, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// SuspendUser keeps a user from logging in and ends their logins until they are reactivated.
+// actorID is the admin doing it, who can't suspend themselves.
+func (s *UserService) SuspendUser(actorID, userID uint) error {
+	if actorID == userID {
+		return errors.New("cannot suspend your own account")
+	}
+	if _, err := s.GetUserByID(userID); err != nil {
+		return err
+	}
+
+	suspended, err := s.userRepo.Suspend(userID, time.Now())
+	if err != nil {
+		if errors.Is(err, interfaces.ErrLastAdmin) {
+			return errors.New("cannot remove the last active admin")
+		}
+		return err
+	}
+	if !suspended {
+		return errors.New("user is already suspended")
+	}
+
+	// Reject the user's tokens and API keys right away
+	s.cache.invalidate(userID)
+	return nil
+}
+
+// ReactivateUser lifts a suspension; the user has to log in again
+func (s *UserService) ReactivateUser(userID uint) error {
+	if _, err := s.GetUserByID(userID); err != nil {
+		return err
+	}
+
+	reactivated, err := s.userRepo.Reactivate(userID)
+	if err != nil {
+		return err
+	}
+	if !reactivated {
+		return errors.New("user is not suspended")
+	}
+
+	s.cache.invalidate(userID)
+	return nil
 }
 
 // UpdateUserRole updates a user's role (admin only)
@@ -138,29 +473,221 @@ func (s *UserService) UpdateUserRole(userID uint, newRole models.UserRole) error
 	}
 
 	// Validate role
-	if newRole != models.RoleUser && newRole != models.RoleAdmin {
+	exists, err := s.roleService.RoleExists(newRole)
+	if err != nil {
+		return err
+	}
+	if !exists {
 		return errors.New("invalid role")
 	}
 
-	user.Role = newRole
-	return s.userRepo.Update(user)
+	if err := s.userRepo.UpdateRole(user.ID, newRole); err != nil {
+		if errors.Is(err, interfaces.ErrLastAdmin) {
+			return errors.New("cannot remove the last active admin")
+		}
+		return err
+	}
+
+	// Existing tokens pick up the new role on their next request
+	s.cache.invalidate(userID)
+	return nil
 }
 
-// ChangePassword changes a user's password
+// ChangePassword changes a user's password after verifying the current one.
+// All tokens issued before the change stop working.
 func (s *UserService) ChangePassword(userID uint, currentPassword, newPassword string) error {
+	user, err := s.userRepo.GetByIDWithCredentials(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		return err
+	}
+
+	// Verify current password
+	valid, err := s.hasher.Verify(currentPassword, user.Password)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return errors.New("current password is incorrect")
+	}
+
+	return s.setPassword(user, newPassword, nil)
+}
+
+// ResetPassword sets a new password for a user who forgot theirs. redeem is called
+// once the new password has passed validation, just before it is stored, so a
+// rejected password doesn't use up the reset token; an error from it aborts the reset.
+// All tokens issued before the reset stop working and the login lockout is lifted.
+func (s *UserService) ResetPassword(userID uint, newPassword string, redeem func() error) error {
+	user, err := s.userRepo.GetByIDWithCredentials(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		return err
+	}
+
+	if err := s.setPassword(user, newPassword, redeem); err != nil {
+		return err
+	}
+	return s.throttleService.ResetAccount(userID)
+}
+
+// setPassword validates and stores a new password, calling beforeSave (if set) once it is accepted
+func (s *UserService) setPassword(user *models.User, newPassword string, beforeSave func() error) error {
 	// Validate new password
-	if err := utils.ValidatePassword(newPassword); err != nil {
+	if err := s.passwordPolicy.Validate(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	// Reject the current password and recently used ones
+	if err := s.checkPasswordReuse(user, newPassword); err != nil {
 		return err
 	}
 
 	// Hash new password
-	hashedPassword, err := utils.HashPassword(newPassword)
+	hashedPassword, err := s.hasher.Hash(newPassword)
 	if err != nil {
 		return err
 	}
 
-	// Note: In a production app, you would verify the current password first
-	// This requires getting the user with password, which our current repository
-	// design doesn't support well. For now, we'll just update the password.
-	return s.userRepo.UpdatePassword(userID, hashedPassword)
+	if beforeSave != nil {
+		if err := beforeSave(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.userRepo.ChangePassword(user.ID, hashedPassword, user.Password, s.passwordHistorySize); err != nil {
+		return err
+	}
+
+	s.cache.invalidate(user.ID)
+	return nil
+}
+
+// checkPasswordReuse rejects a new password matching the current or a recent one
+func (s *UserService) checkPasswordReuse(user *models.User, newPassword string) error {
+	if s.passwordMatches(newPassword, user.Password) {
+		return errors.New("new password must be different from the current password")
+	}
+
+	if s.passwordHistorySize <= 0 {
+		return nil
+	}
+
+	history, err := s.userRepo.GetPasswordHistory(user.ID, s.passwordHistorySize)
+	if err != nil {
+		return err
+	}
+	for _, hash := range history {
+		if s.passwordMatches(newPassword, hash) {
+			return fmt.Errorf("new password must not match any of your last %d passwords", s.passwordHistorySize)
+		}
+	}
+
+	return nil
+}
+
+// confirmIdentity checks that the account holder is making a sensitive change: by their
+// password or, for accounts without one such as those created through the identity
+// provider, by having signed in within recentSignInWindow
+func (s *UserService) confirmIdentity(user *models.User, password string, signedInAt time.Time) error {
+	if user.Password == "" {
+		if signedInAt.IsZero() || time.Since(signedInAt) > recentSignInWindow {
+			return errors.New("sign in again to confirm this change")
+		}
+		return nil
+	}
+	if !s.passwordMatches(password, user.Password) {
+		return errors.New("password is incorrect")
+	}
+	return nil
+}
+
+// passwordMatches checks a password against a stored hash, treating unreadable hashes as no match
+func (s *UserService) passwordMatches(password, hash string) bool {
+	valid, err := s.hasher.Verify(password, hash)
+	return err == nil && valid
+}
+
+// rehashPassword stores a fresh hash of the user's password in place of verifiedHash, the
+// hash it was just checked against. If the password changed since, the new password is
+// kept and nothing is stored. Failing is harmless, the old hash keeps working and the
+// upgrade is retried on the next login.
+func (s *UserService) rehashPassword(userID uint, password, verifiedHash string) {
+	hashedPassword, err := s.hasher.Hash(password)
+	if err == nil {
+		_, err = s.userRepo.RehashPassword(userID, verifiedHash, hashedPassword)
+	}
+	if err != nil {
+		log.Printf("Failed to rehash password of user %d: %v", userID, err)
+	}
+}
+
+// VerifyClaims reconciles token claims with the current user record. Tokens of
+// deleted or suspended users and tokens issued before the user's tokens were invalidated are
+// rejected; the role and identity in the claims are replaced by the current ones
+// so role changes take effect immediately rather than when the token expires.
+func (s *UserService) VerifyClaims(claims *utils.JWTClaims) error {
+	user, err := s.currentUser(claims.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+
+	if claims.TokenVersion != user.TokenVersion {
+		return errors.New("token has been revoked")
+	}
+	if user.IsSuspended() {
+		return errors.New("account is suspended")
+	}
+
+	claims.Role = user.Role
+	claims.Username = user.Username
+	claims.Email = user.Email
+
+	roleScopes, err := s.roleService.ScopesForRole(user.Role)
+	if err != nil {
+		return err
+	}
+	claims.Scopes = grantedScopes(claims.Scopes, roleScopes)
+
+	if s.emailVerification == EmailVerificationWrite && !user.IsEmailVerified() {
+		claims.Scopes = models.ReadOnlyScopes(claims.Scopes)
+	}
+	return nil
+}
+
+// grantedScopes narrows token scopes to those the role still grants
+func grantedScopes(tokenScopes, roleScopes []models.Scope) []models.Scope {
+	granted := make([]models.Scope, 0, len(tokenScopes))
+	for _, scope := range tokenScopes {
+		if models.HasScope(roleScopes, scope) {
+			granted = append(granted, scope)
+		}
+	}
+	return granted
+}
+
+// currentUser returns the user record from the cache or the database.
+// It returns nil without an error when the user doesn't exist.
+func (s *UserService) currentUser(id uint) (*models.User, error) {
+	if user, ok := s.cache.get(id); ok {
+		return user, nil
+	}
+
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		user = nil
+	}
+
+	s.cache.set(id, user)
+	return user, nil
 }
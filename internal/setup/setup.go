@@ -0,0 +1,72 @@
+// Package setup builds the pieces shared by the server and the command line tools
+// from the configuration.
+package setup
+
+import (
+	"fmt"
+
+	"example/go_api_tutorial/internal/config"
+	"example/go_api_tutorial/internal/repository/interfaces"
+	"example/go_api_tutorial/internal/repository/memory"
+	"example/go_api_tutorial/internal/repository/postgres"
+	"example/go_api_tutorial/internal/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// LoginAttemptStore returns the configured store for login and registration attempts
+func LoginAttemptStore(cfg *config.Config, db *gorm.DB) (interfaces.LoginAttemptStore, error) {
+	switch cfg.Throttle.Store {
+	case "memory":
+		return memory.NewLoginAttemptStore(), nil
+	case "postgres":
+		return postgres.NewLoginAttemptStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown THROTTLE_STORE %q, expected memory or postgres", cfg.Throttle.Store)
+	}
+}
+
+// PasswordPolicy builds the password policy from the configuration
+func PasswordPolicy(cfg *config.Config) (utils.PasswordPolicy, error) {
+	policy := utils.PasswordPolicy{
+		MinLength:        cfg.Password.MinLength,
+		MaxLength:        cfg.Password.MaxLength,
+		RequireUpper:     cfg.Password.RequireUpper,
+		RequireLower:     cfg.Password.RequireLower,
+		RequireDigit:     cfg.Password.RequireDigit,
+		RequireSymbol:    cfg.Password.RequireSymbol,
+		DisallowUserInfo: cfg.Password.DisallowUserInfo,
+		MaxRepeated:      cfg.Password.MaxRepeated,
+	}
+
+	if cfg.Password.BreachedDir != "" {
+		breached, err := utils.NewBreachedPasswords(cfg.Password.BreachedDir)
+		if err != nil {
+			return policy, err
+		}
+		policy.Breached = breached
+	}
+	return policy, nil
+}
+
+// PasswordHasher returns the configured password hasher
+func PasswordHasher(cfg *config.Config) (utils.PasswordHasher, error) {
+	switch cfg.Password.Hasher {
+	case "argon2id":
+		params := utils.DefaultArgon2Params()
+		params.Memory = uint32(cfg.Password.Argon2Memory)
+		params.Iterations = uint32(cfg.Password.Argon2Iterations)
+		params.Parallelism = uint8(cfg.Password.Argon2Parallelism)
+		if params.Memory < 8*uint32(params.Parallelism) || params.Iterations < 1 || params.Parallelism < 1 {
+			return nil, fmt.Errorf("invalid argon2 parameters m=%d t=%d p=%d", params.Memory, params.Iterations, params.Parallelism)
+		}
+		return utils.NewArgon2idHasher(params), nil
+	case "bcrypt":
+		if cfg.Password.BcryptCost < bcrypt.MinCost || cfg.Password.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid BCRYPT_COST %d, expected %d to %d", cfg.Password.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost)
+		}
+		return utils.NewBcryptHasher(cfg.Password.BcryptCost), nil
+	default:
+		return nil, fmt.Errorf("unknown PASSWORD_HASHER %q, expected argon2id or bcrypt", cfg.Password.Hasher)
+	}
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"time"
 
 	"example/go_api_tutorial/internal/models"
@@ -10,55 +12,229 @@ import (
 
 // JWTClaims represents the JWT claims
 type JWTClaims struct {
-	UserID   uint             `json:"user_id"`
-	Username string           `json:"username"`
-	Email    string           `json:"email"`
-	Role     models.UserRole  `json:"role"`
+	UserID       uint            `json:"user_id"`
+	Username     string          `json:"username"`
+	Email        string          `json:"email"`
+	Role         models.UserRole `json:"role"`
+	TokenVersion uint            `json:"tv"`
+	SessionID    string          `json:"sid,omitempty"` // refresh token family the token was issued for
+	Scopes       []models.Scope  `json:"scopes"`
+	AMR          []string        `json:"amr,omitempty"`     // how the user authenticated, e.g. pwd and otp
+	Purpose      string          `json:"purpose,omitempty"` // set on challenge tokens, which are not access tokens
+	Act          *Actor          `json:"act,omitempty"`     // set when someone else acts as the user
 	jwt.RegisteredClaims
 }
 
+// Actor identifies who is acting on behalf of a token's subject (act claim, RFC 8693)
+type Actor struct {
+	Subject      string `json:"sub"`
+	UserID       uint   `json:"user_id"`
+	TokenVersion uint   `json:"tv"` // actor's token version when issued
+}
+
+// Authentication methods recorded in the amr claim (RFC 8176)
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+)
+
+// HasAMR checks if the user authenticated with the given method
+func (c *JWTClaims) HasAMR(method string) bool {
+	for _, m := range c.AMR {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
 // JWTManager handles JWT operations
 type JWTManager struct {
-	secretKey string
-	expiresIn time.Duration
+	keys        []*SigningKey // ordered by ActiveFrom
+	gracePeriod time.Duration
+	expiresIn   time.Duration
+	issuer      string
+	audience    []string
 }
 
-// NewJWTManager creates a new JWT manager
+// NewJWTManager creates a new JWT manager signing with a shared HS256 secret
 func NewJWTManager(secretKey string, expiresIn time.Duration) *JWTManager {
 	return &JWTManager{
-		secretKey: secretKey,
+		keys: []*SigningKey{{
+			Method:    jwt.SigningMethodHS256,
+			SignKey:   []byte(secretKey),
+			VerifyKey: []byte(secretKey),
+		}},
 		expiresIn: expiresIn,
 	}
 }
 
-// GenerateToken generates a JWT token for a user
-func (j *JWTManager) GenerateToken(user *models.User) (string, error) {
-	claims := JWTClaims{
-		UserID:   user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		Role:     user.Role,
+// NewJWTManagerWithKeys creates a JWT manager signing with asymmetric keys identified by kid.
+// Each key signs from its ActiveFrom time until the next signing key takes over, after
+// which it keeps verifying tokens for gracePeriod so tokens signed just before the
+// rotation stay valid.
+func NewJWTManagerWithKeys(keys []*SigningKey, gracePeriod, expiresIn time.Duration) (*JWTManager, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+
+	seen := make(map[string]bool)
+	canSign := false
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing keys must have an ID")
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate signing key ID %q", key.ID)
+		}
+		seen[key.ID] = true
+		canSign = canSign || key.CanSign()
+	}
+	if !canSign {
+		return nil, errors.New("at least one key must hold a private key")
+	}
+
+	sorted := append([]*SigningKey(nil), keys...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].ActiveFrom.Before(sorted[b].ActiveFrom)
+	})
+
+	return &JWTManager{
+		keys:        sorted,
+		gracePeriod: gracePeriod,
+		expiresIn:   expiresIn,
+	}, nil
+}
+
+// WithAudience sets the issuer stamped on tokens and the audiences they are minted for.
+// Validation then rejects tokens from another issuer or for none of these audiences,
+// so deployments sharing a key don't accept each other's tokens.
+func (j *JWTManager) WithAudience(issuer string, audience []string) *JWTManager {
+	j.issuer = issuer
+	j.audience = audience
+	return j
+}
+
+// GenerateToken generates a short-lived access token for a user's session with the
+// given scopes and authentication methods
+func (j *JWTManager) GenerateToken(user *models.User, sessionID string, scopes []models.Scope, amr []string) (string, error) {
+	claims, err := j.newClaims(user, j.expiresIn)
+	if err != nil {
+		return "", err
+	}
+	claims.SessionID = sessionID
+	claims.Scopes = scopes
+	claims.AMR = amr
+
+	return j.sign(claims)
+}
+
+// GenerateImpersonationToken generates an access token letting actor act as user for ttl.
+// It belongs to no session, so it can't be refreshed.
+func (j *JWTManager) GenerateImpersonationToken(user *models.User, actor *models.User, scopes []models.Scope, amr []string, ttl time.Duration) (string, error) {
+	claims, err := j.newClaims(user, ttl)
+	if err != nil {
+		return "", err
+	}
+	claims.Scopes = scopes
+	claims.AMR = amr
+	claims.Act = &Actor{Subject: actor.Username, UserID: actor.ID, TokenVersion: actor.TokenVersion}
+
+	return j.sign(claims)
+}
+
+// GenerateChallengeToken generates a token proving a step of a multi-step flow, such as
+// a correct password awaiting a TOTP code. It is only accepted by ValidateChallengeToken
+// with the same purpose, never as an access token.
+func (j *JWTManager) GenerateChallengeToken(user *models.User, purpose string, ttl time.Duration) (string, error) {
+	claims, err := j.newClaims(user, ttl)
+	if err != nil {
+		return "", err
+	}
+	claims.Purpose = purpose
+
+	return j.sign(claims)
+}
+
+// newClaims builds the claims identifying a user for a token valid for ttl
+func (j *JWTManager) newClaims(user *models.User, ttl time.Duration) (*JWTClaims, error) {
+	tokenID, err := RandomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &JWTClaims{
+		UserID:       user.ID,
+		Username:     user.Username,
+		Email:        user.Email,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiresIn)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    j.issuer,
+			Audience:  j.audience,
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 			Subject:   user.Username,
+			ID:        tokenID,
 		},
+	}, nil
+}
+
+// sign signs claims with the current signing key
+func (j *JWTManager) sign(claims *JWTClaims) (string, error) {
+	key, err := j.signingKey(time.Now())
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secretKey))
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.SignKey)
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// ValidateToken validates an access token and returns the claims
 func (j *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// ValidateChallengeToken validates a challenge token issued for purpose and returns the claims
+func (j *JWTManager) ValidateChallengeToken(tokenString, purpose string) (*JWTClaims, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if purpose == "" || claims.Purpose != purpose {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// parse verifies a token's signature, expiry, issuer and audience and returns its claims
+func (j *JWTManager) parse(tokenString string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := j.verificationKey(kid, time.Now())
+		if key == nil {
+			return nil, errors.New("unknown signing key")
+		}
+
 		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, errors.New("invalid signing method")
 		}
-		return []byte(j.secretKey), nil
-	})
+		return key.VerifyKey, nil
+	}, j.parserOptions()...)
 
 	if err != nil {
 		return nil, err
@@ -71,27 +247,73 @@ func (j *JWTManager) ValidateToken(tokenString string) (*JWTClaims, error) {
 	return nil, errors.New("invalid token")
 }
 
-// RefreshToken generates a new token from an existing valid token
-func (j *JWTManager) RefreshToken(tokenString string) (string, error) {
-	claims, err := j.ValidateToken(tokenString)
-	if err != nil {
-		return "", err
+// ExpiresIn returns how long issued access tokens stay valid
+func (j *JWTManager) ExpiresIn() time.Duration {
+	return j.expiresIn
+}
+
+// parserOptions returns the issuer and audience checks for ValidateToken
+func (j *JWTManager) parserOptions() []jwt.ParserOption {
+	var options []jwt.ParserOption
+	if j.issuer != "" {
+		options = append(options, jwt.WithIssuer(j.issuer))
+	}
+	if len(j.audience) > 0 {
+		options = append(options, jwt.WithAudience(j.audience...))
 	}
+	return options
+}
 
-	// Create new token with extended expiration
-	newClaims := JWTClaims{
-		UserID:   claims.UserID,
-		Username: claims.Username,
-		Email:    claims.Email,
-		Role:     claims.Role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiresIn)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Subject:   claims.Username,
-		},
+// JWKS returns the public keys other services can verify tokens with: the keys
+// currently accepted plus keys scheduled to take over, so verifiers can cache
+// them before they are used. It is empty when signing with a shared secret.
+func (j *JWTManager) JWKS() JWKS {
+	now := time.Now()
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range j.keys {
+		if key.ActiveFrom.After(now) || j.accepts(key, now) {
+			if jwk, err := key.toJWK(); err == nil {
+				set.Keys = append(set.Keys, jwk)
+			}
+		}
 	}
+	return set
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims)
-	return token.SignedString([]byte(j.secretKey))
+// signingKey returns the newest key holding a private key that is active at now
+func (j *JWTManager) signingKey(now time.Time) (*SigningKey, error) {
+	var current *SigningKey
+	for _, key := range j.keys {
+		if key.CanSign() && !key.ActiveFrom.After(now) {
+			current = key
+		}
+	}
+	if current == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return current, nil
+}
+
+// verificationKey returns the key with the given kid if tokens signed with it are accepted at now
+func (j *JWTManager) verificationKey(kid string, now time.Time) *SigningKey {
+	for _, key := range j.keys {
+		if key.ID == kid && j.accepts(key, now) {
+			return key
+		}
+	}
+	return nil
+}
+
+// accepts checks if a key is active at now, or was replaced less than the grace period ago
+func (j *JWTManager) accepts(key *SigningKey, now time.Time) bool {
+	if key.ActiveFrom.After(now) {
+		return false
+	}
+
+	for _, next := range j.keys {
+		if next.CanSign() && next.ActiveFrom.After(key.ActiveFrom) && !next.ActiveFrom.After(now) {
+			return now.Before(next.ActiveFrom.Add(j.gracePeriod))
+		}
+	}
+	return true
 }
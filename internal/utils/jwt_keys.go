@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a key the JWT manager signs or verifies tokens with
+type SigningKey struct {
+	ID         string // kid header of tokens signed with this key
+	Method     jwt.SigningMethod
+	SignKey    interface{} // nil for verify-only keys
+	VerifyKey  interface{}
+	ActiveFrom time.Time // when the key takes over signing
+}
+
+// CanSign checks if the key holds private material
+func (k *SigningKey) CanSign() bool {
+	return k.SignKey != nil
+}
+
+// LoadSigningKey loads an RSA (RS256) or Ed25519 (EdDSA) key from a PEM file.
+// A private key can sign and verify; a public key can only verify, which is
+// useful for retired keys whose private half has been destroyed.
+func LoadSigningKey(id, path string, activeFrom time.Time) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %w", id, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s: no PEM data found in %s", id, path)
+	}
+
+	key := &SigningKey{ID: id, ActiveFrom: activeFrom}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		key.Method, key.SignKey, key.VerifyKey = jwt.SigningMethodRS256, privateKey, &privateKey.PublicKey
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		switch privateKey := parsed.(type) {
+		case *rsa.PrivateKey:
+			key.Method, key.SignKey, key.VerifyKey = jwt.SigningMethodRS256, privateKey, &privateKey.PublicKey
+		case ed25519.PrivateKey:
+			key.Method, key.SignKey, key.VerifyKey = jwt.SigningMethodEdDSA, privateKey, privateKey.Public()
+		default:
+			return nil, fmt.Errorf("key %s: unsupported private key type %T", id, parsed)
+		}
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		switch publicKey := parsed.(type) {
+		case *rsa.PublicKey:
+			key.Method, key.VerifyKey = jwt.SigningMethodRS256, publicKey
+		case ed25519.PublicKey:
+			key.Method, key.VerifyKey = jwt.SigningMethodEdDSA, publicKey
+		default:
+			return nil, fmt.Errorf("key %s: unsupported public key type %T", id, parsed)
+		}
+	default:
+		return nil, fmt.Errorf("key %s: unsupported PEM block %q", id, block.Type)
+	}
+
+	return key, nil
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// toJWK converts the public half of an asymmetric key to a JWK
+func (k *SigningKey) toJWK() (JWK, error) {
+	jwk := JWK{Use: "sig", Kid: k.ID, Alg: k.Method.Alg()}
+
+	switch publicKey := k.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JWK{}, errors.New("key has no public form")
+	}
+
+	return jwk, nil
+}
@@ -2,39 +2,28 @@ package utils
 
 import (
 	"errors"
-	"golang.org/x/crypto/bcrypt"
 )
 
-// HashPassword hashes a password using bcrypt
-func HashPassword(password string) (string, error) {
-	if len(password) < 6 {
-		return "", errors.New("password must be at least 6 characters long")
-	}
-	
-	hashedBytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	
-	return string(hashedBytes), nil
+// DefaultPasswordHasher returns the hasher used when none is configured: argon2id
+// with the OWASP recommended parameters
+func DefaultPasswordHasher() PasswordHasher {
+	return NewArgon2idHasher(DefaultArgon2Params())
 }
 
-// CheckPassword compares a password with its hash
-func CheckPassword(password, hashedPassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+// HashPassword hashes a password with the default hasher. Validate it against the
+// password policy first; hashing doesn't check its strength.
+func HashPassword(password string) (string, error) {
+	return DefaultPasswordHasher().Hash(password)
 }
 
-// ValidatePassword validates password strength
-func ValidatePassword(password string) error {
-	if len(password) < 6 {
-		return errors.New("password must be at least 6 characters long")
+// CheckPassword compares a password with its argon2id or bcrypt hash
+func CheckPassword(password, hashedPassword string) error {
+	ok, err := verifyPassword(password, hashedPassword)
+	if err != nil {
+		return err
 	}
-	
-	if len(password) > 100 {
-		return errors.New("password must be less than 100 characters")
+	if !ok {
+		return errors.New("password does not match")
 	}
-	
-
-	
 	return nil
 }
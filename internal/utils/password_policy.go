@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy rules reported in violations
+const (
+	RuleMinLength     = "min_length"
+	RuleMaxLength     = "max_length"
+	RuleUppercase     = "uppercase"
+	RuleLowercase     = "lowercase"
+	RuleDigit         = "digit"
+	RuleSymbol        = "symbol"
+	RuleUserInfo      = "user_info"
+	RuleRepeatedChars = "repeated_chars"
+	RuleBreached      = "breached"
+)
+
+// PasswordPolicy describes what makes a password acceptable
+type PasswordPolicy struct {
+	MinLength     int // in characters
+	MaxLength     int // in characters, 0 for no limit
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// DisallowUserInfo rejects passwords containing the username or the local part of the email
+	DisallowUserInfo bool
+	// MaxRepeated is the longest allowed run of one character, 0 for no limit
+	MaxRepeated int
+	// Breached, when set, rejects passwords known from data breaches
+	Breached *BreachedPasswords
+}
+
+// PasswordViolation is one rule a password breaks
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError lists every rule a password breaks
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// DefaultPasswordPolicy returns the policy used when none is configured
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: 6, MaxLength: 100}
+}
+
+// Validate checks a password against the policy. username and email are the account's,
+// for DisallowUserInfo. It returns a *PasswordPolicyError listing every broken rule, or
+// another error if the breached password list can't be read.
+func (p PasswordPolicy) Validate(password, username, email string) error {
+	var violations []PasswordViolation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, PasswordViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		add(RuleMinLength, "password must be at least %d characters long", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(RuleMaxLength, "password must be at most %d characters long", p.MaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		add(RuleUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		add(RuleLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		add(RuleDigit, "password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		add(RuleSymbol, "password must contain a symbol")
+	}
+
+	if p.DisallowUserInfo && containsUserInfo(password, username, email) {
+		add(RuleUserInfo, "password must not contain your username or email address")
+	}
+
+	if p.MaxRepeated > 0 && longestRun(password) > p.MaxRepeated {
+		add(RuleRepeatedChars, "password must not repeat a character more than %d times in a row", p.MaxRepeated)
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			add(RuleBreached, "password has appeared in a data breach, choose another one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsUserInfo checks if the password contains the username or the email's local part.
+// Very short values are ignored since they match too much by chance.
+func containsUserInfo(password, username, email string) bool {
+	lowered := strings.ToLower(password)
+	local, _, _ := strings.Cut(email, "@")
+	for _, value := range []string{username, local} {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if utf8.RuneCountInString(value) >= 3 && strings.Contains(lowered, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// longestRun returns the length of the longest run of one repeated character
+func longestRun(s string) int {
+	longest, run := 0, 0
+	var previous rune
+	for i, r := range s {
+		if i > 0 && r == previous {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		previous = r
+	}
+	return longest
+}
+
+// BreachedPasswords checks passwords against a local copy of a breached password
+// list split by k-anonymity hash prefix, in the format of the Pwned Passwords range
+// API: one file per 5-character SHA-1 prefix (e.g. 21BD1 or 21BD1.txt), each with a
+// line of SUFFIX:COUNT per breached hash. Only the file for the password's prefix is read.
+type BreachedPasswords struct {
+	dir string
+}
+
+// NewBreachedPasswords opens the prefix files in dir
+func NewBreachedPasswords(dir string) (*BreachedPasswords, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password list %s is not a directory", dir)
+	}
+	return &BreachedPasswords{dir: dir}, nil
+}
+
+// Contains checks if the password is in the list. A missing prefix file counts as no match.
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := b.open(prefix)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		candidate, count, _ := strings.Cut(line, ":")
+		if strings.EqualFold(candidate, suffix) && strings.TrimSpace(count) != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// open opens the file for a hash prefix, with or without a .txt extension
+func (b *BreachedPasswords) open(prefix string) (*os.File, error) {
+	file, err := os.Open(filepath.Join(b.dir, prefix))
+	if errors.Is(err, os.ErrNotExist) {
+		return os.Open(filepath.Join(b.dir, prefix+".txt"))
+	}
+	return file, err
+}
@@ -0,0 +1,30 @@
+-- The schema of the first release, which created it with AutoMigrate. Tables and indexes are only
+-- created when missing, so databases set up by any earlier release are adopted by this and the
+-- following migrations.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "username" varchar(50) NOT NULL,
+    "email" varchar(100) NOT NULL,
+    "password" text NOT NULL,
+    "role" varchar(20) DEFAULT 'user',
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_username" ON "users" ("username");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "books" (
+    "id" bigserial,
+    "title" varchar(255) NOT NULL,
+    "author" varchar(255) NOT NULL,
+    "quantity" bigint DEFAULT 0,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_books_deleted_at" ON "books" ("deleted_at");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "loans";
+ALTER TABLE "books"
+    DROP COLUMN IF EXISTS "available";
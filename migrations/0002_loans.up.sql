@@ -0,0 +1,26 @@
+-- Copies not on loan. Books from before loans are all on the shelf.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'books' AND column_name = 'available'
+    ) THEN
+        ALTER TABLE "books" ADD COLUMN "available" bigint DEFAULT 0;
+        UPDATE "books" SET "available" = "quantity";
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS "loans" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "book_id" bigint NOT NULL,
+    "checked_out_at" timestamptz NOT NULL,
+    "due_at" timestamptz NOT NULL,
+    "returned_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_loans_user_id" ON "loans" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_loans_book_id" ON "loans" ("book_id");
+CREATE INDEX IF NOT EXISTS "idx_loans_due_at" ON "loans" ("due_at");
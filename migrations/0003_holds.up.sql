@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "holds" (
+    "id" bigserial,
+    "book_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "status" varchar(20) DEFAULT 'waiting',
+    "ready_at" timestamptz,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_holds_book_id" ON "holds" ("book_id");
+CREATE INDEX IF NOT EXISTS "idx_holds_user_id" ON "holds" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_holds_status" ON "holds" ("status");
@@ -0,0 +1,11 @@
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "token_version" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "password_history" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "password_hash" text NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_password_history_user_id" ON "password_history" ("user_id");
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS "sessions" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "family_id" varchar(32) NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "token_version" bigint NOT NULL DEFAULT 0,
+    "user_agent" varchar(255),
+    "ip_address" varchar(45),
+    "expires_at" timestamptz NOT NULL,
+    "rotated_at" timestamptz,
+    "revoked_at" timestamptz,
+    "last_seen_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sessions_token_hash" ON "sessions" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_sessions_user_id" ON "sessions" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_sessions_family_id" ON "sessions" ("family_id");
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "revoked_tokens";
+ALTER TABLE "sessions"
+    DROP COLUMN IF EXISTS "device",
+    DROP COLUMN IF EXISTS "started_at";
@@ -0,0 +1,16 @@
+ALTER TABLE "sessions"
+    ADD COLUMN IF NOT EXISTS "device" varchar(100),
+    ADD COLUMN IF NOT EXISTS "started_at" timestamptz;
+-- Sessions from before started_at began when they were created
+UPDATE "sessions" SET "started_at" = "created_at" WHERE "started_at" IS NULL;
+ALTER TABLE "sessions" ALTER COLUMN "started_at" SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+    "id" bigserial,
+    "identifier" varchar(64) NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_revoked_tokens_identifier" ON "revoked_tokens" ("identifier");
+CREATE INDEX IF NOT EXISTS "idx_revoked_tokens_expires_at" ON "revoked_tokens" ("expires_at");
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+    "key" varchar(255),
+    "count" bigint NOT NULL DEFAULT 0,
+    "window_ends_at" timestamptz NOT NULL,
+    "locked_until" timestamptz,
+    PRIMARY KEY ("key")
+);
+CREATE INDEX IF NOT EXISTS "idx_login_attempts_window_ends_at" ON "login_attempts" ("window_ends_at");
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "recovery_codes";
+ALTER TABLE "sessions"
+    DROP COLUMN IF EXISTS "two_factor";
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS "two_factor_enabled",
+    DROP COLUMN IF EXISTS "totp_secret",
+    DROP COLUMN IF EXISTS "totp_last_step";
@@ -0,0 +1,16 @@
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "two_factor_enabled" boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS "totp_secret" varchar(64),
+    ADD COLUMN IF NOT EXISTS "totp_last_step" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "sessions"
+    ADD COLUMN IF NOT EXISTS "two_factor" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "code_hash" varchar(64) NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
@@ -0,0 +1,11 @@
+-- Users who signed up before email verification existed are trusted as they are.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'email_verified_at'
+    ) THEN
+        ALTER TABLE "users" ADD COLUMN "email_verified_at" timestamptz;
+        UPDATE "users" SET "email_verified_at" = "created_at";
+    END IF;
+END $$;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "password_resets" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "token_hash" varchar(64) NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_password_resets_token_hash" ON "password_resets" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_password_resets_user_id" ON "password_resets" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_password_resets_expires_at" ON "password_resets" ("expires_at");
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS "api_keys" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "prefix" varchar(16) NOT NULL,
+    "key_hash" varchar(64) NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "last_used_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_api_keys_key_hash" ON "api_keys" ("key_hash");
+CREATE INDEX IF NOT EXISTS "idx_api_keys_user_id" ON "api_keys" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "api_key_permissions" (
+    "api_key_id" bigint,
+    "permission_id" bigint,
+    PRIMARY KEY ("api_key_id", "permission_id"),
+    CONSTRAINT "fk_api_key_permissions_api_key" FOREIGN KEY ("api_key_id") REFERENCES "api_keys" ("id"),
+    CONSTRAINT "fk_api_key_permissions_permission" FOREIGN KEY ("permission_id") REFERENCES "permissions" ("id")
+);
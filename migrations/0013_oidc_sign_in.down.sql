@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "oidc_logins";
+DROP INDEX IF EXISTS "idx_users_oidc_identity";
+ALTER TABLE "users"
+    DROP COLUMN IF EXISTS "oidc_issuer",
+    DROP COLUMN IF EXISTS "oidc_subject";
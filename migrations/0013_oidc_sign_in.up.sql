@@ -0,0 +1,16 @@
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "oidc_issuer" varchar(255),
+    ADD COLUMN IF NOT EXISTS "oidc_subject" varchar(255);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_oidc_identity" ON "users" ("oidc_issuer", "oidc_subject");
+
+CREATE TABLE IF NOT EXISTS "oidc_logins" (
+    "id" bigserial,
+    "state_hash" varchar(64) NOT NULL,
+    "nonce" varchar(64) NOT NULL,
+    "code_verifier" varchar(128) NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_oidc_logins_state_hash" ON "oidc_logins" ("state_hash");
+CREATE INDEX IF NOT EXISTS "idx_oidc_logins_expires_at" ON "oidc_logins" ("expires_at");
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "audit_events" (
+    "id" bigserial,
+    "action" varchar(50) NOT NULL,
+    "user_id" bigint NOT NULL,
+    "actor_id" bigint,
+    "method" varchar(10),
+    "path" varchar(255),
+    "status" bigint,
+    "ip_address" varchar(45),
+    "user_agent" varchar(255),
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_audit_events_action" ON "audit_events" ("action");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_user_id" ON "audit_events" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_actor_id" ON "audit_events" ("actor_id");
+CREATE INDEX IF NOT EXISTS "idx_audit_events_created_at" ON "audit_events" ("created_at");
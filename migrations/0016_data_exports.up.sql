@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "data_exports" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "status" varchar(20) DEFAULT 'pending',
+    "file_path" varchar(255),
+    "completed_at" timestamptz,
+    "expires_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_data_exports_user_id" ON "data_exports" ("user_id");
+CREATE INDEX IF NOT EXISTS "idx_data_exports_status" ON "data_exports" ("status");
+CREATE INDEX IF NOT EXISTS "idx_data_exports_expires_at" ON "data_exports" ("expires_at");
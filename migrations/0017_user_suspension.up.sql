@@ -0,0 +1,3 @@
+ALTER TABLE "users"
+    ADD COLUMN IF NOT EXISTS "suspended_at" timestamptz;
+CREATE INDEX IF NOT EXISTS "idx_users_suspended_at" ON "users" ("suspended_at");
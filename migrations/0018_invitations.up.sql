@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "invitations" (
+    "id" bigserial,
+    "prefix" varchar(16) NOT NULL,
+    "code_hash" varchar(64) NOT NULL,
+    "role" varchar(20) NOT NULL,
+    "email" varchar(100),
+    "max_uses" bigint NOT NULL DEFAULT 1,
+    "uses" bigint NOT NULL DEFAULT 0,
+    "expires_at" timestamptz NOT NULL,
+    "created_by_id" bigint NOT NULL,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_invitations_code_hash" ON "invitations" ("code_hash");
+CREATE INDEX IF NOT EXISTS "idx_invitations_created_by_id" ON "invitations" ("created_by_id");
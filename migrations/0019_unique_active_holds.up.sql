@@ -0,0 +1,23 @@
+-- A user has at most one waiting or ready hold per book. Duplicates placed before this was
+-- enforced are cancelled, keeping a ready hold over a waiting one and otherwise the oldest,
+-- and the copies reserved for cancelled ready holds go back on the shelf.
+WITH "cancelled" AS (
+    UPDATE "holds" SET "status" = 'cancelled', "updated_at" = now()
+    WHERE "id" IN (
+        SELECT "id" FROM (
+            SELECT "id", row_number() OVER (PARTITION BY "user_id", "book_id" ORDER BY "status" = 'ready' DESC, "id") AS "rank"
+            FROM "holds"
+            WHERE "status" IN ('waiting', 'ready')
+        ) AS "ranked"
+        WHERE "rank" > 1
+    )
+    RETURNING "book_id", "ready_at"
+)
+UPDATE "books" SET "available" = LEAST("quantity", "available" + "released"."copies")
+FROM (
+    SELECT "book_id", count(*) AS "copies" FROM "cancelled" WHERE "ready_at" IS NOT NULL GROUP BY "book_id"
+) AS "released"
+WHERE "books"."id" = "released"."book_id";
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_holds_active_user_book" ON "holds" ("user_id", "book_id")
+    WHERE "status" IN ('waiting', 'ready');
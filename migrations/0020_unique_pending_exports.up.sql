@@ -0,0 +1,14 @@
+-- A user has at most one export being built. Duplicates started before this was enforced
+-- are marked failed, keeping the oldest; they are cleaned up like any other failed export.
+UPDATE "data_exports" SET "status" = 'failed', "completed_at" = now(), "expires_at" = now(), "updated_at" = now()
+WHERE "id" IN (
+    SELECT "id" FROM (
+        SELECT "id", row_number() OVER (PARTITION BY "user_id" ORDER BY "id") AS "rank"
+        FROM "data_exports"
+        WHERE "status" = 'pending'
+    ) AS "ranked"
+    WHERE "rank" > 1
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_data_exports_pending_user" ON "data_exports" ("user_id")
+    WHERE "status" = 'pending';
@@ -0,0 +1,11 @@
+// Package migrations holds the numbered SQL migrations of the database schema.
+// Each version has a NNNN_name.up.sql file applying it and a NNNN_name.down.sql
+// file reverting it.
+package migrations
+
+import "embed"
+
+// FS contains the migration files
+//
+//go:embed *.sql
+var FS embed.FS